@@ -0,0 +1,136 @@
+package cml_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/shengdoushi/base58"
+	"github.com/stretchr/testify/require"
+)
+
+// 按 a 模式规则用 shengdoushi/base58 生成参照编码
+func referenceA(f *cml.CMLDouble) string {
+	var sb strings.Builder
+	for i, tok := range f.Tokens {
+		sb.WriteString(base58.Encode([]byte(tok), base58.BitcoinAlphabet))
+		if i < len(f.Relations) {
+			sb.WriteString(f.Relations[i])
+		}
+	}
+	return "a" + base58.Encode([]byte(sb.String()), base58.BitcoinAlphabet)
+}
+
+func randomToken(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	// 一部分token带前导零字节，覆盖 '1' 前缀的处理
+	for range r.IntN(3) * r.IntN(2) {
+		sb.WriteByte(0)
+	}
+	for sb.Len() < n {
+		switch r.IntN(3) {
+		case 0:
+			sb.WriteByte(byte(r.IntN(0x80)))
+		case 1:
+			sb.WriteRune(rune(0x4e00 + r.IntN(0x5000)))
+		default:
+			sb.WriteRune(rune(0x10000 + r.IntN(0x1000)))
+		}
+	}
+	return sb.String()
+}
+
+// a 模式编解码测试：与 shengdoushi/base58 逐字节一致，覆盖分组运算与 math/big 两条路径
+func TestCML_Base58Compat(t *testing.T) {
+	ast := require.New(t)
+	r := rand.New(rand.NewPCG(58, 58))
+	rels := []cml.Relation{cml.RelRemark, cml.RelLinear, cml.RelSet, cml.RelMapping, cml.RelCombine}
+
+	for _, size := range []int{1, 2, 3, 4, 5, 7, 31, 255, 1500, 6000} {
+		// 参照实现为平方复杂度，大输入少测几轮
+		rounds := 8
+		if size > 1000 {
+			rounds = 2
+		}
+		for range rounds {
+			f := cml.Build(randomToken(r, 1+r.IntN(size)))
+			for range r.IntN(4) {
+				f.Then(rels[r.IntN(len(rels))], randomToken(r, 1+r.IntN(size)))
+			}
+			want := referenceA(f)
+			got, err := f.EncodeA()
+			ast.NoError(err)
+			ast.Equal(want, got, "size %d", size)
+
+			back, err := cml.CML2Fragments(want)
+			ast.NoError(err)
+			ast.Equal(f.Tokens, back.Tokens)
+			ast.Equal(f.TypedRelations(), back.TypedRelations())
+		}
+	}
+
+	for _, bad := range []string{"a0", "aO1", "a2Il", "a2 3", "a中文"} {
+		_, err := cml.CML2Fragments(bad)
+		ast.Error(err, bad)
+	}
+}
+
+var benchSizes = []int{1 << 10, 16 << 10, 256 << 10, 1 << 20}
+
+func benchFragment(n int) *cml.CMLDouble {
+	r := rand.New(rand.NewPCG(1, 2))
+	var sb strings.Builder
+	for sb.Len() < n {
+		sb.WriteByte(byte('!' + r.IntN(90)))
+	}
+	return cml.Build(sb.String())
+}
+
+func sizeName(n int) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%dMB", n>>20)
+	}
+	return fmt.Sprintf("%dKB", n>>10)
+}
+
+func BenchmarkCML_EncodeA(b *testing.B) {
+	for _, n := range benchSizes {
+		f := benchFragment(n)
+		b.Run(sizeName(n), func(b *testing.B) {
+			b.SetBytes(int64(n))
+			for b.Loop() {
+				if _, err := f.EncodeA(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		// 参照实现为平方复杂度，只比较较小的输入
+		if n <= 16<<10 {
+			b.Run(sizeName(n)+"/shengdoushi", func(b *testing.B) {
+				b.SetBytes(int64(n))
+				for b.Loop() {
+					referenceA(f)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCML_DecodeA(b *testing.B) {
+	for _, n := range benchSizes {
+		encoded, err := benchFragment(n).EncodeA()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(sizeName(n), func(b *testing.B) {
+			b.SetBytes(int64(n))
+			for b.Loop() {
+				if _, err := cml.CML2Fragments(encoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 链式构造测试：结果应与 New 手动构造完全一致
+func TestCML_Build(t *testing.T) {
+	ast := require.New(t)
+
+	built := cml.Build("万有引力").Map("牛顿").Set("自然哲学的数学原理").Remark("1687 年")
+	ast.NoError(built.IsValid())
+
+	manual, err := cml.New([]string{"万有引力", ":", "牛顿", "+", "自然哲学的数学原理", "@", "1687 年"})
+	ast.NoError(err)
+	ast.Equal(manual, built)
+
+	pBuilt, err := built.EncodeP()
+	ast.NoError(err)
+	pManual, err := manual.EncodeP()
+	ast.NoError(err)
+	ast.Equal(pManual, pBuilt)
+
+	// 非法关系符在编码时才会暴露
+	bad := cml.Build("A").Then(cml.Relation('x'), "B")
+	ast.Error(bad.IsValid())
+	_, err = bad.EncodeA()
+	ast.Error(err)
+}
@@ -0,0 +1,132 @@
+package main
+
+/**
+在 Markdown 与 HTML 文档中定位CML：
+1、HTML 属性 data-cml="..." 或 data-cml='...'
+2、Markdown 图片替代文本 ![cml-...](...)
+3、悬停与代码操作还会识别光标下以 a/c/q/p 开头的单词
+*/
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 文档中的一段CML编码，start/end 为字节偏移
+type span struct {
+	start, end int
+	text       string
+}
+
+var (
+	attrPattern  = regexp.MustCompile(`data-cml\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	imagePattern = regexp.MustCompile(`!\[cml-([^\]]*)\]`)
+)
+
+// 文档中明确标注为CML的全部片段
+func findMarked(text string) []span {
+	var spans []span
+	for _, m := range attrPattern.FindAllStringSubmatchIndex(text, -1) {
+		for g := 2; g <= 4; g += 2 {
+			if m[g] >= 0 {
+				spans = append(spans, span{start: m[g], end: m[g+1], text: text[m[g]:m[g+1]]})
+			}
+		}
+	}
+	for _, m := range imagePattern.FindAllStringSubmatchIndex(text, -1) {
+		spans = append(spans, span{start: m[2], end: m[3], text: text[m[2]:m[3]]})
+	}
+	return spans
+}
+
+// 单词边界：空白、引号和常见的标记符号
+func isWordBreak(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("\"'`<>()[]{},;=", r)
+}
+
+// 光标处的CML：优先使用标注过的片段，否则取光标下的单词
+func spanAt(text string, offset int) (span, bool) {
+	for _, s := range findMarked(text) {
+		if offset >= s.start && offset <= s.end {
+			return s, s.text != ""
+		}
+	}
+	start, end := offset, offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if isWordBreak(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if isWordBreak(r) {
+			break
+		}
+		end += size
+	}
+	word := text[start:end]
+	// Markdown 图片替代文本之外也接受 cml- 前缀
+	if strings.HasPrefix(word, "cml-") {
+		start += len("cml-")
+		word = text[start:end]
+	}
+	if word == "" || !strings.ContainsRune("acqp", rune(word[0])) {
+		return span{}, false
+	}
+	return span{start: start, end: end, text: word}, true
+}
+
+/**
+------------------------ 位置换算 --------------------------
+LSP 的列号以 UTF-16 码元计，文档内部使用字节偏移
+*/
+
+// 字节偏移 -> LSP 位置
+func positionOf(text string, offset int) position {
+	offset = min(max(offset, 0), len(text))
+	line := strings.Count(text[:offset], "\n")
+	lineStart := strings.LastIndexByte(text[:offset], '\n') + 1
+	col := 0
+	for _, r := range text[lineStart:offset] {
+		col += utf16Len(r)
+	}
+	return position{Line: line, Character: col}
+}
+
+// LSP 位置 -> 字节偏移，越界时收敛到行尾或文末
+func offsetOf(text string, pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	col := 0
+	for offset < len(text) && col < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == '\n' {
+			break
+		}
+		col += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if utf16.IsSurrogate(r) || r < 0x10000 {
+		return 1
+	}
+	return 2
+}
+
+func rangeOf(text string, s span) lspRange {
+	return lspRange{Start: positionOf(text, s.start), End: positionOf(text, s.end)}
+}
@@ -0,0 +1,15 @@
+// cml-lsp 是CML的语言服务器，通过标准输入输出与编辑器通信。
+//
+// 支持的功能:
+//
+//	诊断      解码 data-cml 属性与 ![cml-...] 图片中的CML，报告解码错误
+//	悬停      展示光标下 a/c/q/p 编码解码后的内容
+//	代码操作  在四种模式间转换
+//	补全      关系符 @ . + : 与空格
+package main
+
+import "os"
+
+func main() {
+	os.Exit(newServer(os.Stdin, os.Stdout).serve())
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// 模式名称与转换函数，顺序即代码操作的顺序
+var modes = []struct {
+	mode    byte
+	name    string
+	convert func(string) (string, error)
+}{
+	{'a', "双重 Base58", cml.CML2A},
+	{'c', "双重 Base64URL", cml.CML2C},
+	{'q', "混合编码", cml.CML2Q},
+	{'p', "明文", cml.CML2P},
+}
+
+type server struct {
+	conn        *conn
+	mu          sync.Mutex
+	docs        map[string]string
+	initialized bool
+	shutdown    bool
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{conn: newConn(r, w), docs: map[string]string{}}
+}
+
+// 处理消息直到 exit 或输入结束，返回进程退出码
+func (s *server) serve() int {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rpcErr *rpcError
+			if errors.As(err, &rpcErr) {
+				s.conn.write(&message{ID: json.RawMessage("null"), Error: rpcErr})
+				continue
+			}
+			return 1
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rpcErr := s.handle(msg)
+		// 通知没有 id，不需要响应
+		if msg.ID == nil {
+			continue
+		}
+		resp := &message{ID: msg.ID, Error: rpcErr}
+		if rpcErr == nil {
+			resp.Result = result
+			if result == nil {
+				resp.Result = json.RawMessage("null")
+			}
+		}
+		s.conn.write(resp)
+	}
+}
+
+func (s *server) handle(msg *message) (any, *rpcError) {
+	if !s.initialized && msg.Method != "initialize" {
+		return nil, &rpcError{Code: codeNotInitialized, Message: "服务器尚未初始化"}
+	}
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		return map[string]any{
+			"capabilities": map[string]any{
+				"positionEncoding":   "utf-16",
+				"textDocumentSync":   1, // 全量同步
+				"hoverProvider":      true,
+				"codeActionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{}},
+			},
+			"serverInfo": map[string]string{"name": "cml-lsp"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.mu.Lock()
+		delete(s.docs, p.TextDocument.URI)
+		s.mu.Unlock()
+		s.publish(p.TextDocument.URI, []diagnostic{})
+		return nil, nil
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(p), nil
+	case "textDocument/codeAction":
+		var p codeActionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.codeActions(p), nil
+	case "textDocument/completion":
+		return completions(), nil
+	}
+	if strings.HasPrefix(msg.Method, "$/") {
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "不支持的方法: " + msg.Method}
+}
+
+func invalidParams(err error) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+func (s *server) text(uri string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text, ok := s.docs[uri]
+	return text, ok
+}
+
+// 保存文档并推送诊断
+func (s *server) update(uri, text string) {
+	s.mu.Lock()
+	s.docs[uri] = text
+	s.mu.Unlock()
+	s.publish(uri, diagnose(text))
+}
+
+func (s *server) publish(uri string, diags []diagnostic) {
+	s.conn.write(&message{
+		Method: "textDocument/publishDiagnostics",
+		Params: mustMarshal(publishDiagnosticsParams{URI: uri, Diagnostics: diags}),
+	})
+}
+
+func mustMarshal(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// 文档内容不可信，解码时使用缺省限制
+func decode(encoded string) (*cml.CMLDouble, error) {
+	return cml.CML2FragmentsWith(encoded, cml.UntrustedDecodeOptions())
+}
+
+// 对标注过的CML逐个解码，解码失败即为诊断
+func diagnose(text string) []diagnostic {
+	diags := []diagnostic{}
+	for _, sp := range findMarked(text) {
+		msg := ""
+		if sp.text == "" {
+			msg = "空的CML编码"
+		} else if _, err := decode(sp.text); err != nil {
+			msg = err.Error()
+		}
+		if msg != "" {
+			diags = append(diags, diagnostic{
+				Range:    rangeOf(text, sp),
+				Severity: severityError,
+				Source:   "cml",
+				Message:  msg,
+			})
+		}
+	}
+	return diags
+}
+
+// 悬停时展示解码后的内容
+func (s *server) hover(p textDocumentPositionParams) *hover {
+	text, ok := s.text(p.TextDocument.URI)
+	if !ok {
+		return nil
+	}
+	sp, ok := spanAt(text, offsetOf(text, p.Position))
+	if !ok {
+		return nil
+	}
+	f, err := decode(sp.text)
+	if err != nil {
+		return nil
+	}
+	pretty, err := cml.FormatPretty(f)
+	if err != nil {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "**CML** %s模式，%d 个词元\n\n", modeName(sp.text[0]), len(f.Tokens))
+	fmt.Fprintf(&b, "```\n%s\n```\n", pretty)
+	r := rangeOf(text, sp)
+	return &hover{Contents: markupContent{Kind: "markdown", Value: b.String()}, Range: &r}
+}
+
+func modeName(mode byte) string {
+	for _, m := range modes {
+		if m.mode == mode {
+			return m.name
+		}
+	}
+	return string(mode)
+}
+
+// 为光标处的CML提供转换到其它模式的操作
+func (s *server) codeActions(p codeActionParams) []codeAction {
+	actions := []codeAction{}
+	text, ok := s.text(p.TextDocument.URI)
+	if !ok {
+		return actions
+	}
+	sp, ok := spanAt(text, offsetOf(text, p.Range.Start))
+	if !ok {
+		return actions
+	}
+	if _, err := decode(sp.text); err != nil {
+		return actions
+	}
+	r := rangeOf(text, sp)
+	for _, m := range modes {
+		if m.mode == sp.text[0] {
+			continue
+		}
+		converted, err := m.convert(sp.text)
+		if err != nil {
+			continue
+		}
+		actions = append(actions, codeAction{
+			Title: fmt.Sprintf("转换为 %c 模式（%s）", m.mode, m.name),
+			Kind:  "refactor.rewrite",
+			Edit: workspaceEdit{Changes: map[string][]textEdit{
+				p.TextDocument.URI: {{Range: r, NewText: converted}},
+			}},
+		})
+	}
+	return actions
+}
+
+// 关系符补全
+func completions() []completionItem {
+	rels := []cml.Relation{cml.RelRemark, cml.RelLinear, cml.RelSet, cml.RelMapping, cml.RelCombine}
+	items := make([]completionItem, 0, len(rels))
+	for _, rel := range rels {
+		label := string(rel.Symbol())
+		if rel == cml.RelCombine {
+			label = "␠"
+		}
+		items = append(items, completionItem{
+			Label:      label,
+			Kind:       completionOperator,
+			Detail:     rel.Name(),
+			InsertText: string(rel.Symbol()),
+		})
+	}
+	return items
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+)
+
+func frame(t *testing.T, buf *bytes.Buffer, id int, method string, params any) {
+	t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(buf, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestPositionUTF16(t *testing.T) {
+	text := "第一行\n😀x data-cml=\"pA\""
+	off := strings.Index(text, "x")
+	pos := positionOf(text, off)
+	if pos.Line != 1 || pos.Character != 2 {
+		t.Fatalf("positionOf = %+v", pos)
+	}
+	if got := offsetOf(text, pos); got != off {
+		t.Fatalf("offsetOf = %d, want %d", got, off)
+	}
+	if got := offsetOf(text, position{Line: 5}); got != len(text) {
+		t.Fatalf("越界位置 = %d", got)
+	}
+}
+
+func TestSession(t *testing.T) {
+	a, err := cml.CML2A("p你好.世界")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := "<span data-cml=\"" + a + "\"></span>\n![cml-q坏](x.png)\n"
+	uri := "file:///doc.md"
+
+	var in bytes.Buffer
+	frame(t, &in, 1, "initialize", map[string]any{})
+	frame(t, &in, 0, "initialized", map[string]any{})
+	frame(t, &in, 0, "textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "text": doc}})
+	hoverPos := map[string]any{"line": 0, "character": 20}
+	frame(t, &in, 2, "textDocument/hover", map[string]any{"textDocument": map[string]any{"uri": uri}, "position": hoverPos})
+	frame(t, &in, 3, "textDocument/codeAction", map[string]any{"textDocument": map[string]any{"uri": uri}, "range": map[string]any{"start": hoverPos, "end": hoverPos}})
+	frame(t, &in, 4, "textDocument/completion", map[string]any{"textDocument": map[string]any{"uri": uri}, "position": hoverPos})
+	frame(t, &in, 5, "shutdown", nil)
+	frame(t, &in, 0, "exit", nil)
+
+	var out bytes.Buffer
+	if code := newServer(&in, &out).serve(); code != 0 {
+		t.Fatalf("退出码 = %d", code)
+	}
+
+	replies := map[string]json.RawMessage{}
+	var diags publishDiagnosticsParams
+	c := newConn(&out, nil)
+	for {
+		msg, err := c.read()
+		if err != nil {
+			break
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			if err := json.Unmarshal(msg.Params, &diags); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		raw, _ := json.Marshal(msg.Result)
+		replies[string(msg.ID)] = raw
+	}
+
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Range.Start.Line != 1 {
+		t.Fatalf("诊断 = %+v", diags.Diagnostics)
+	}
+
+	var h hover
+	if err := json.Unmarshal(replies["2"], &h); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(h.Contents.Value, "你好") || !strings.Contains(h.Contents.Value, "世界") {
+		t.Fatalf("悬停内容 = %q", h.Contents.Value)
+	}
+
+	var actions []codeAction
+	if err := json.Unmarshal(replies["3"], &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("代码操作数 = %d", len(actions))
+	}
+	for _, act := range actions {
+		edit := act.Edit.Changes[uri][0]
+		if edit.Range.Start.Character != len(`<span data-cml="`) {
+			t.Fatalf("编辑范围 = %+v", edit.Range)
+		}
+		if strings.HasPrefix(act.Title, "转换为 p") && edit.NewText != "p你好.世界" {
+			t.Fatalf("转换结果 = %q", edit.NewText)
+		}
+	}
+
+	var items []completionItem
+	if err := json.Unmarshal(replies["4"], &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 5 || items[0].InsertText != "@" {
+		t.Fatalf("补全 = %+v", items)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// 单个片段的校验结果
+type checkResult struct {
+	lineResult
+	Violations []cml.Violation `json:"violations"`
+}
+
+// cml check -schema 文件 [-format text|json] [CML编码...]
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "形状模式文件")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: cml check -schema 文件 [-format text|json] [CML编码...]")
+		fmt.Fprintln(stderr, "未给出CML编码时从标准输入逐行读取，每行一个片段；任一片段不符合时以 1 退出")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *schemaPath == "" {
+		fmt.Fprintln(stderr, "cml check: 缺少 -schema")
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "cml check: 未知的输出格式 %q\n", *format)
+		return 2
+	}
+	src, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml check: %v\n", err)
+		return 1
+	}
+	schema, err := cml.CompileSchema(string(src))
+	if err != nil {
+		fmt.Fprintf(stderr, "cml check: %s: %v\n", *schemaPath, err)
+		return 1
+	}
+
+	inputs, err := readLines(fs.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml check: %v\n", err)
+		return 1
+	}
+	return checkLines("check", *format, inputs, stdout, stderr,
+		func(encoded string) ([]cml.Violation, bool, error) {
+			violations, err := schema.ValidateEncoded(encoded)
+			return violations, len(violations) > 0, err
+		},
+		func(res lineResult, violations []cml.Violation) any {
+			return checkResult{res, violations}
+		})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/ContextMark/cml-go/cmllint"
+)
+
+// 单个片段的检查结果
+type lintResult struct {
+	lineResult
+	Findings []cmllint.Finding `json:"findings"`
+}
+
+// cml lint [-config 文件] [-format text|json] [-fail 级别] [CML编码...]
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	config := fs.String("config", "", "JSON 配置文件，缺省启用全部规则")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fail := fs.String("fail", "error", "出现不低于该级别的问题时以 1 退出: info、warning 或 error")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: cml lint [-config 文件] [-format text|json] [-fail 级别] [CML编码...]")
+		fmt.Fprintln(stderr, "未给出CML编码时从标准输入逐行读取，每行一个片段")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "cml lint: 未知的输出格式 %q\n", *format)
+		return 2
+	}
+	threshold, err := cmllint.ParseSeverity(*fail)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml lint: %v\n", err)
+		return 2
+	}
+
+	var cfg *cmllint.Config
+	if *config != "" {
+		if cfg, err = cmllint.LoadConfig(*config); err != nil {
+			fmt.Fprintf(stderr, "cml lint: %v\n", err)
+			return 1
+		}
+	}
+	linter, err := cmllint.New(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml lint: %v\n", err)
+		return 1
+	}
+
+	inputs, err := readLines(fs.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml lint: %v\n", err)
+		return 1
+	}
+	return checkLines("lint", *format, inputs, stdout, stderr,
+		func(encoded string) ([]cmllint.Finding, bool, error) {
+			findings, err := linter.LintEncoded(encoded)
+			for _, f := range findings {
+				if f.Severity >= threshold {
+					return findings, true, err
+				}
+			}
+			return findings, false, err
+		},
+		func(res lineResult, findings []cmllint.Finding) any {
+			return lintResult{res, findings}
+		})
+}
@@ -0,0 +1,155 @@
+// cml 是CML的命令行工具。
+//
+// 用法:
+//
+//	cml <子命令> [参数]
+//
+// 子命令:
+//
+//	check 按形状模式校验CML片段
+//	lint  按可配置的规则检查CML片段
+//	viz   将CML编码渲染为 Mermaid 或 Graphviz 图
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 子命令入口，返回进程退出码
+type command struct {
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"check": {summary: "按形状模式校验CML片段", run: runCheck},
+	"lint":  {summary: "按可配置的规则检查CML片段", run: runLint},
+	"viz":   {summary: "将CML编码渲染为 Mermaid 或 Graphviz 图", run: runViz},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "cml: 未知的子命令 %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdin, stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "用法: cml <子命令> [参数]")
+	fmt.Fprintln(w, "子命令:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-6s %s\n", name, commands[name].summary)
+	}
+}
+
+// 读取输入：优先使用位置参数，否则读取标准输入，首尾空白被去除
+func readInput(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	b, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// 输出到文件或标准输出
+func writeOutput(path, content string, stdout io.Writer) error {
+	if path == "" || path == "-" {
+		_, err := io.WriteString(stdout, content)
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// 逐行检查的输入：优先使用位置参数，否则按行读取标准输入，每行一个片段
+func readLines(args []string, stdin io.Reader) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	var lines []string
+	sc := bufio.NewScanner(stdin)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines, sc.Err()
+}
+
+// 单个片段检查结果的公共部分，子命令嵌入后追加各自的问题列表
+type lineResult struct {
+	Line    int    `json:"line"`
+	Encoded string `json:"encoded"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 逐行检查并按 text 或 json 输出，跳过空行
+// check 返回片段的问题以及是否应以 1 退出，wrap 把公共部分和问题组装为 JSON 输出的结果
+func checkLines[T fmt.Stringer](name, format string, inputs []string, stdout, stderr io.Writer,
+	check func(encoded string) ([]T, bool, error), wrap func(lineResult, []T) any) int {
+	failed := false
+	results := []any{}
+	for i, line := range inputs {
+		encoded := strings.TrimSpace(line)
+		if encoded == "" {
+			continue
+		}
+		res := lineResult{Line: i + 1, Encoded: encoded}
+		issues, fail, err := check(encoded)
+		if err != nil {
+			res.Error = err.Error()
+			fail = true
+		}
+		if issues == nil {
+			issues = []T{}
+		}
+		failed = failed || fail
+		if format == "json" {
+			results = append(results, wrap(res, issues))
+			continue
+		}
+		if res.Error != "" {
+			fmt.Fprintf(stdout, "%d: 解码失败: %s\n", res.Line, res.Error)
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(stdout, "%d: %s\n", res.Line, issue)
+		}
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintf(stderr, "cml %s: %v\n", name, err)
+			return 1
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
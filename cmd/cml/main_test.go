@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 子命令的一次调用
+type cliCase struct {
+	name     string
+	args     []string
+	stdin    string
+	code     int
+	contains []string // 标准输出应包含的片段
+}
+
+func runCases(t *testing.T, cases []cliCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+			if code != c.code {
+				t.Fatalf("退出码 = %d, want %d\nstdout: %s\nstderr: %s", code, c.code, stdout.String(), stderr.String())
+			}
+			for _, want := range c.contains {
+				if !strings.Contains(stdout.String(), want) {
+					t.Fatalf("输出缺少 %q:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	runCases(t, []cliCase{
+		{name: "无子命令", code: 2},
+		{name: "未知子命令", args: []string{"nope"}, code: 2},
+	})
+}
+
+func TestViz(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "graph.mmd")
+	runCases(t, []cliCase{
+		{name: "mermaid", args: []string{"viz", "pA:B"}, code: 0,
+			contains: []string{"graph LR", `t0["A"]`, "t0 -->|mapping| t1"}},
+		{name: "dot", args: []string{"viz", "-format", "dot", "-fence", "pA:B"}, code: 0,
+			contains: []string{"```dot\ndigraph cml {", `t0 -> t1 [label="mapping"]`, "}\n```\n"}},
+		{name: "标准输入", args: []string{"viz"}, stdin: " pA@B\n", code: 0, contains: []string{"remark"}},
+		{name: "输出到文件", args: []string{"viz", "-o", out, "pA:B"}, code: 0},
+		{name: "非法编码", args: []string{"viz", "xA:B"}, code: 1},
+		{name: "未知格式", args: []string{"viz", "-format", "svg", "pA:B"}, code: 2},
+		{name: "未知参数", args: []string{"viz", "-bad"}, code: 2},
+	})
+	b, err := os.ReadFile(out)
+	if err != nil || !strings.HasPrefix(string(b), "graph LR") {
+		t.Fatalf("输出文件 = %q, %v", b, err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "lint.json")
+	if err := os.WriteFile(config, []byte(`{"rules": {"duplicate-set-member": {"enabled": false}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"rules": {"no-such-rule": {}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runCases(t, []cliCase{
+		{name: "低于失败级别", args: []string{"lint", "p苹果+苹果", "pA:B"}, code: 0,
+			contains: []string{"1: warning [duplicate-set-member] token 1"}},
+		{name: "达到失败级别", args: []string{"lint", "-fail", "warning", "p苹果+苹果"}, code: 1},
+		{name: "json", args: []string{"lint", "-format", "json", "-fail", "warning", "p苹果+苹果"}, code: 1,
+			contains: []string{`"line": 1`, `"encoded": "p苹果+苹果"`, `"rule": "duplicate-set-member"`, `"severity": "warning"`}},
+		{name: "json无问题", args: []string{"lint", "-format", "json", "pA:B"}, code: 0, contains: []string{`"findings": []`}},
+		{name: "标准输入跳过空行", args: []string{"lint", "-fail", "warning"}, stdin: "pA:B\n\np苹果+苹果\n", code: 1,
+			contains: []string{"3: warning"}},
+		{name: "解码失败", args: []string{"lint", "xA"}, code: 1, contains: []string{"1: 解码失败"}},
+		{name: "配置关闭规则", args: []string{"lint", "-config", config, "-fail", "info", "p苹果+苹果"}, code: 0},
+		{name: "非法配置", args: []string{"lint", "-config", bad, "pA:B"}, code: 1},
+		{name: "未知格式", args: []string{"lint", "-format", "xml", "pA:B"}, code: 2},
+		{name: "未知级别", args: []string{"lint", "-fail", "fatal", "pA:B"}, code: 2},
+	})
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "concept.schema")
+	if err := os.WriteFile(schema, []byte("Concept : Person (@ Year)?\nYear = /^\\d{4}$/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.schema")
+	if err := os.WriteFile(bad, []byte("Concept : (\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runCases(t, []cliCase{
+		{name: "符合", args: []string{"check", "-schema", schema, "p万有引力:牛顿@1687", "p万有引力:牛顿"}, code: 0},
+		{name: "约束不满足", args: []string{"check", "-schema", schema, "p万有引力:牛顿@明末"}, code: 1,
+			contains: []string{`1: token 2: "明末" 不满足 Year`}},
+		{name: "形状不符", args: []string{"check", "-schema", schema}, stdin: "p万有引力:牛顿\np万有引力@牛顿\n", code: 1,
+			contains: []string{"2: token 1: 期望 : Person"}},
+		{name: "json", args: []string{"check", "-schema", schema, "-format", "json", "p万有引力:牛顿@明末"}, code: 1,
+			contains: []string{`"line": 1`, `"index": 2`, `"name": "Year"`, `"message": "\"明末\" 不满足`}},
+		{name: "json符合", args: []string{"check", "-schema", schema, "-format", "json", "p万有引力:牛顿"}, code: 0,
+			contains: []string{`"violations": []`}},
+		{name: "解码失败", args: []string{"check", "-schema", schema, "xA"}, code: 1, contains: []string{"1: 解码失败"}},
+		{name: "缺少schema", args: []string{"check", "pA:B"}, code: 2},
+		{name: "schema语法错误", args: []string{"check", "-schema", bad, "pA:B"}, code: 1},
+		{name: "schema不存在", args: []string{"check", "-schema", filepath.Join(dir, "none"), "pA:B"}, code: 1},
+		{name: "未知格式", args: []string{"check", "-schema", schema, "-format", "xml", "pA:B"}, code: 2},
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// cml viz [-format mermaid|dot] [-fence] [-o 文件] [CML编码]
+func runViz(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("viz", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "mermaid", "输出格式: mermaid 或 dot")
+	fence := fs.Bool("fence", false, "用 Markdown 代码块包裹输出，可直接粘贴进 README")
+	out := fs.String("o", "", "输出文件，缺省为标准输出")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: cml viz [-format mermaid|dot] [-fence] [-o 文件] [CML编码]")
+		fmt.Fprintln(stderr, "未给出CML编码时从标准输入读取")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	encoded, err := readInput(fs.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "cml viz: %v\n", err)
+		return 1
+	}
+	var diagram, lang string
+	switch *format {
+	case "mermaid":
+		diagram, err = cml.ToMermaid(encoded)
+		lang = "mermaid"
+	case "dot":
+		diagram, err = cml.ToDOT(encoded)
+		lang = "dot"
+	default:
+		fmt.Fprintf(stderr, "cml viz: 不支持的输出格式 %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cml viz: %v\n", err)
+		return 1
+	}
+	if *fence {
+		diagram = "```" + lang + "\n" + diagram + "```\n"
+	}
+	if err := writeOutput(*out, diagram, stdout); err != nil {
+		fmt.Fprintf(stderr, "cml viz: %v\n", err)
+		return 1
+	}
+	return 0
+}
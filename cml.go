@@ -9,6 +9,8 @@ package cml
 */
 
 import (
+	"iter"
+
 	"github.com/ContextMark/cml-go/internal"
 )
 
@@ -57,6 +59,83 @@ func CML2Fragments(encoded string) (*CMLDouble, error) {
 	return internal.CML2Fragments(encoded)
 }
 
+// Elements 流式解码CML字符串，按需逐个产出基元，消费方 break 后停止解码
+// 出错时产出一次 (零值, err) 后结束
+func Elements(encoded string) iter.Seq2[Element, error] {
+	return internal.ElementSeq(encoded)
+}
+
+// ElementsWith 按解码选项流式解码，限制与取消在逐个token解码时生效
+func ElementsWith(encoded string, opts *DecodeOptions) iter.Seq2[Element, error] {
+	return internal.ElementSeqWith(encoded, opts)
+}
+
+// Repair 宽松解码大模型生成或手工编辑的CML，返回尽力修复后的双序列和全部修复记录
+// 删除连续关系符之间的空token和首尾关系符，无法解码的 ! 转义token按明文保留，缺少模式前缀时按 p 模式解析
+func Repair(encoded string) (*CMLDouble, []RepairFix, error) {
+	return internal.Repair(encoded)
+}
+
+// RepairWith 按选项宽松解码，限制与取消同 CML2FragmentsWith，Strict 被忽略
+func RepairWith(encoded string, opts *DecodeOptions) (*CMLDouble, []RepairFix, error) {
+	return internal.RepairWith(encoded, opts)
+}
+
+// UntrustedDecodeOptions 适用于不可信输入的解码限制，每次调用返回新的实例
+func UntrustedDecodeOptions() *DecodeOptions {
+	return internal.UntrustedDecodeOptions()
+}
+
+// CML2FragmentsWith 按解码选项将CML字符串解析为双序列
+func CML2FragmentsWith(encoded string, opts *DecodeOptions) (*CMLDouble, error) {
+	return internal.CML2FragmentsWith(encoded, opts)
+}
+
+// CML2ElementsWith 按解码选项将CML字符串解析为单序列
+func CML2ElementsWith(encoded string, opts *DecodeOptions) (*CMLSingle, error) {
+	return internal.CML2ElementsWith(encoded, opts)
+}
+
+// NewInterner 创建并发安全的token驻留器，可通过 DecodeOptions 传入解码
+func NewInterner() *Interner {
+	return internal.NewInterner()
+}
+
+// CML2Tree 将CML字符串解码为展开嵌套token后的树，maxDepth <= 0 时使用缺省深度
+func CML2Tree(encoded string, maxDepth int) (*CMLTree, error) {
+	return internal.CML2Tree(encoded, maxDepth)
+}
+
+// CML2TreeWith 按选项解码并展开嵌套，限制、取消与严格模式同样作用于每一层子CML
+func CML2TreeWith(encoded string, maxDepth int, opts *DecodeOptions) (*CMLTree, error) {
+	return internal.CML2TreeWith(encoded, maxDepth, opts)
+}
+
+// Nest 将子序列包装为嵌套token，用于 (A + B) : C 这样的分组
+func Nest(sub *CMLDouble) (string, error) {
+	return internal.Nest(sub)
+}
+
+// Unnest 解析嵌套token，得到子序列
+func Unnest(token string) (*CMLDouble, error) {
+	return internal.Unnest(token)
+}
+
+// IsNested 判断token是否为合法的嵌套token
+func IsNested(token string) bool {
+	return internal.IsNested(token)
+}
+
+// EscapeLiteral 转义形似嵌套的普通token，使其按树解码时保持原值
+func EscapeLiteral(token string) string {
+	return internal.EscapeLiteral(token)
+}
+
+// UnescapeLiteral 还原 EscapeLiteral 转义过的原文
+func UnescapeLiteral(token string) string {
+	return internal.UnescapeLiteral(token)
+}
+
 // 将cml编码转换成md反引号格式
 func ToMarkdown(encoded string) string {
 	return toMarkdown(encoded)
@@ -72,6 +151,17 @@ func New(arr []string) (*CMLDouble, error) {
 	return internal.New(arr)
 }
 
+// ParseRelation 将单字节解析为类型化关系符
+func ParseRelation(b byte) (Relation, error) {
+	return internal.ParseRelation(b)
+}
+
+// Build 链式构造CML双基元序列，交替规律由构造方式保证
+// cml.Build("万有引力").Map("牛顿").Set("自然哲学的数学原理").Remark("1687 年")
+func Build(token string) *CMLDouble {
+	return internal.Build(token)
+}
+
 /*
 -----------------------------定义完全等价的别名-----------------------------
 ---------------不需要强制转换到底层类型的指针类型，零复制、零开销--------------
@@ -86,3 +176,115 @@ type CMLSingle = internal.CmlElements
 // - 偶数序列<separator>，<separator>，...<separator>
 // 由于CML有交替规律，使用双列表，比使用基元类型抽象的编解码转换性能更高，可以显著减少内存分配次数
 type CMLDouble = internal.CmlFragments
+
+// 解码选项
+type DecodeOptions = internal.DecodeOptions
+
+// 解码超出 DecodeOptions 限制时返回的错误
+type LimitError = internal.LimitError
+
+// 严格模式遇到非规范编码时返回的错误及其类别
+type (
+	StrictError = internal.StrictError
+	StrictKind  = internal.StrictKind
+)
+
+// 非规范编码的4种类别
+const (
+	StrictNeedlessEscape     = internal.StrictNeedlessEscape     // 不含保留字符的token被 ! 转义
+	StrictNonCanonicalBase64 = internal.StrictNonCanonicalBase64 // base64 填充位非零或含有被忽略的字符
+	StrictInvalidUTF8        = internal.StrictInvalidUTF8        // token解码后不是合法的 UTF-8
+	StrictUnescapedReserved  = internal.StrictUnescapedReserved  // 明文token包含未转义的保留字符 !
+)
+
+// 编码前校验失败时返回的错误及其类别
+type (
+	EncodeError     = internal.EncodeError
+	EncodeErrorKind = internal.EncodeErrorKind
+)
+
+// 编码前校验失败的类别
+const (
+	EncodeInvalidStructure = internal.EncodeInvalidStructure // nil、空序列或token与关系符不交替
+	EncodeInvalidRelation  = internal.EncodeInvalidRelation  // 非法的关系符
+	EncodeEmptyToken       = internal.EncodeEmptyToken       // 空token
+	EncodeInvalidUTF8      = internal.EncodeInvalidUTF8      // token不是合法的 UTF-8
+)
+
+// Repair 应用的一条修复及其类别
+type (
+	RepairFix  = internal.RepairFix
+	RepairKind = internal.RepairKind
+)
+
+// 修复类别
+const (
+	RepairMissingMode  = internal.RepairMissingMode  // 缺少模式前缀，按 p 模式解析
+	RepairEmptyToken   = internal.RepairEmptyToken   // 连续关系符之间的空token被删除
+	RepairEdgeRelation = internal.RepairEdgeRelation // 首尾多余的关系符被删除
+	RepairBadEscape    = internal.RepairBadEscape    // ! 转义token无法解码，按明文保留
+	RepairModeFallback = internal.RepairModeFallback // token无法按声明的模式解码，去掉前缀后按 p 模式解析
+)
+
+// UntrustedDecodeOptions 使用的缺省限制
+const (
+	UntrustedMaxInputBytes = internal.UntrustedMaxInputBytes
+	UntrustedMaxTokens     = internal.UntrustedMaxTokens
+	UntrustedMaxTokenBytes = internal.UntrustedMaxTokenBytes
+)
+
+// token驻留接口，可以替换为自定义实现
+type TokenInterner = internal.TokenInterner
+
+// 并发安全的token驻留器及其统计
+type (
+	Interner    = internal.Interner
+	InternStats = internal.InternStats
+)
+
+// 以token编号表示的双序列
+type IDFragments = internal.IDFragments
+
+// 展开嵌套token后的树形结构
+type CMLTree = internal.CmlTree
+
+// 树节点，Sub 非nil时为嵌套token
+type TreeNode = internal.TreeNode
+
+// 缺省的最大嵌套深度
+const DefaultMaxNestDepth = internal.DefaultMaxNestDepth
+
+// 单序列和双序列共同实现的中间结构接口，下游代码可以同时接收两者
+type Sequence = internal.Sequence
+
+// 4种编码模式标识，可用于 CMLDouble.Encode
+const (
+	ModeA = internal.ModeA // 双层 Base58
+	ModeC = internal.ModeC // 双层 Base64URL
+	ModeQ = internal.ModeQ // 双层混编
+	ModeP = internal.ModeP // 单层明文混编
+)
+
+// 语义基元，单序列与迭代器产出的单元
+type Element = internal.CmlElement
+
+// 基元类型
+const (
+	TypeToken     = internal.TypeToken
+	TypeSeparator = internal.TypeSeparator
+)
+
+// 相邻两个token及连接它们的关系符
+type Triple = internal.Triple
+
+// 类型化的关系符
+type Relation = internal.Relation
+
+// 5个关系符常量
+const (
+	RelRemark  = internal.RelRemark  // @ 补充关系
+	RelLinear  = internal.RelLinear  // . 线性递进
+	RelSet     = internal.RelSet     // + 并列集合
+	RelMapping = internal.RelMapping // : 映射关系
+	RelCombine = internal.RelCombine // 空格 组合关系
+)
@@ -0,0 +1,113 @@
+package cmlgraph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// WriteDOT 导出为 Graphviz DOT，无向边以 dir=none 表示
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph cml {")
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(bw, "  %s;\n", cml.QuoteDOT(n.ID))
+	}
+	for _, e := range g.Edges() {
+		attrs := fmt.Sprintf("label=%s", cml.QuoteDOT(e.Label))
+		if !e.Directed {
+			attrs += ", dir=none"
+		}
+		if e.Weight > 1 {
+			attrs += fmt.Sprintf(", weight=%d", e.Weight)
+		}
+		fmt.Fprintf(bw, "  %s -> %s [%s];\n", cml.QuoteDOT(e.From), cml.QuoteDOT(e.To), attrs)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteGraphML 导出为 GraphML，节点ID为 n0、n1...，token原文存放在 label 属性中
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="label" for="all" attr.name="label" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="count" for="node" attr.name="count" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <graph id="cml" edgedefault="directed">`)
+	ids := make(map[string]string, len(g.nodeOrder))
+	for i, n := range g.Nodes() {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(bw, "    <node id=%q><data key=\"label\">%s</data><data key=\"count\">%d</data></node>\n",
+			ids[n.ID], xmlEscape(n.ID), n.Count)
+	}
+	for i, e := range g.Edges() {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=%q target=%q directed=\"%t\"><data key=\"label\">%s</data><data key=\"weight\">%d</data></edge>\n",
+			i, ids[e.From], ids[e.To], e.Directed, xmlEscape(e.Label), e.Weight)
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// WriteCypher 导出为单条 Cypher CREATE 语句
+// 节点标签为 Token，token原文存放在 name 属性；关系类型为边类型的大写形式，
+// Cypher 的关系必须有方向，无向边按规范化后的端点创建并带 undirected: true 属性
+func (g *Graph) WriteCypher(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	vars := make(map[string]string, len(g.nodeOrder))
+	var clauses []string
+	for i, n := range g.Nodes() {
+		vars[n.ID] = fmt.Sprintf("n%d", i)
+		clauses = append(clauses, fmt.Sprintf("(%s:Token {name: %s, count: %d})", vars[n.ID], cypherQuote(n.ID), n.Count))
+	}
+	for _, e := range g.Edges() {
+		props := fmt.Sprintf("weight: %d", e.Weight)
+		if !e.Directed {
+			props += ", undirected: true"
+		}
+		clauses = append(clauses, fmt.Sprintf("(%s)-[:%s {%s}]->(%s)", vars[e.From], cypherType(e.Label), props, vars[e.To]))
+	}
+	if len(clauses) == 0 {
+		return bw.Flush()
+	}
+	fmt.Fprintf(bw, "CREATE\n  %s;\n", strings.Join(clauses, ",\n  "))
+	return bw.Flush()
+}
+
+// Cypher 的字符串字面量
+func cypherQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// 关系类型：字母、数字和下划线组成的标签转为大写直接使用，
+// 其余标签原样放入反引号，内部的反引号写两次；空标签为 RELATED
+func cypherType(label string) string {
+	if label == "" {
+		return "RELATED"
+	}
+	upper := strings.ToUpper(label)
+	plain := upper[0] < '0' || upper[0] > '9'
+	for _, r := range upper {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return upper
+	}
+	return "`" + strings.ReplaceAll(label, "`", "``") + "`"
+}
@@ -0,0 +1,206 @@
+// Package cmlgraph 将CML片段投影为节点和边构成的图。
+//
+// token投影为节点，相同内容的token合并为同一节点；关系符按可配置的规则投影为带类型的边，
+// 例如 : 链成为有向边、+ 集合成为两两相连的兄弟成员。多个片段投影到同一张内存图中，
+// 重复的边累加权重，最终可以导出为 Graphviz DOT、GraphML 或 Cypher CREATE 脚本。
+package cmlgraph
+
+import (
+	"fmt"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// EdgeKind 关系符投影成边的方式
+type EdgeKind int
+
+const (
+	Skip       EdgeKind = iota // 不产生边
+	Directed                   // 左token -> 右token 的有向边
+	Undirected                 // 左右token之间的无向边
+	Sibling                    // 以该关系符连续连接的token构成一组，组内两两无向相连
+)
+
+// Rule 单个关系符的投影规则
+type Rule struct {
+	Kind  EdgeKind
+	Label string // 边类型，空串时使用关系符的语义名称
+}
+
+// Rules 投影规则集合
+type Rules struct {
+	ByRelation map[cml.Relation]Rule
+	// DistributeOverSets 为 true 时，有向边或无向边一端连接的是 Sibling 组的边缘成员时，
+	// 边会分发到组内全部成员，例如 万有引力 : 牛顿 + 莱布尼茨 产生两条 mapping 边
+	DistributeOverSets bool
+}
+
+// DefaultRules 缺省规则：@ . : 为有向边，+ 为兄弟集合并分发，空格组合为无向边
+func DefaultRules() Rules {
+	return Rules{
+		ByRelation: map[cml.Relation]Rule{
+			cml.RelRemark:  {Kind: Directed},
+			cml.RelLinear:  {Kind: Directed},
+			cml.RelMapping: {Kind: Directed},
+			cml.RelSet:     {Kind: Sibling},
+			cml.RelCombine: {Kind: Undirected},
+		},
+		DistributeOverSets: true,
+	}
+}
+
+func (r Rules) rule(rel cml.Relation) Rule {
+	rule := r.ByRelation[rel]
+	if rule.Label == "" {
+		rule.Label = rel.Name()
+	}
+	return rule
+}
+
+// Node 图节点，即一个去重后的token
+type Node struct {
+	ID    string // token原文
+	Count int    // 在全部片段中出现的次数
+}
+
+// Edge 图的边，Weight 为重复投影的次数
+type Edge struct {
+	From     string
+	To       string
+	Label    string
+	Directed bool
+	Weight   int
+}
+
+type edgeKey struct {
+	from, to, label string
+	directed        bool
+}
+
+// Graph 内存图，节点和边保持首次出现的顺序，导出结果稳定
+type Graph struct {
+	nodes     map[string]*Node
+	nodeOrder []string
+	edges     map[edgeKey]*Edge
+	edgeOrder []edgeKey
+}
+
+// New 创建空图
+func New() *Graph {
+	return &Graph{
+		nodes: make(map[string]*Node),
+		edges: make(map[edgeKey]*Edge),
+	}
+}
+
+// Project 将一批片段按规则投影到同一张图
+func Project(frags []*cml.CMLDouble, rules Rules) (*Graph, error) {
+	g := New()
+	for i, f := range frags {
+		if err := g.Add(f, rules); err != nil {
+			return nil, fmt.Errorf("cmlgraph: 第 %d 个片段: %w", i, err)
+		}
+	}
+	return g, nil
+}
+
+// Add 将单个片段按规则合并进图
+func (g *Graph) Add(f *cml.CMLDouble, rules Rules) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	for _, token := range f.Tokens {
+		g.node(token).Count++
+	}
+
+	// 先划分 Sibling 组：group[i] 为第 i 个token所在组的 [起, 止] token索引
+	n := len(f.Tokens)
+	groupStart, groupEnd := make([]int, n), make([]int, n)
+	for i := 0; i < n; {
+		j := i
+		for j < n-1 && rules.rule(f.RelationAt(j)).Kind == Sibling {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			groupStart[k], groupEnd[k] = i, j
+		}
+		if j > i {
+			label := rules.rule(f.RelationAt(i)).Label
+			for a := i; a <= j; a++ {
+				for b := a + 1; b <= j; b++ {
+					g.edge(f.Tokens[a], f.Tokens[b], label, false)
+				}
+			}
+		}
+		i = j + 1
+	}
+
+	for i := range f.Relations {
+		rule := rules.rule(f.RelationAt(i))
+		if rule.Kind != Directed && rule.Kind != Undirected {
+			continue
+		}
+		lefts, rights := []int{i}, []int{i + 1}
+		if rules.DistributeOverSets {
+			// 左侧是组的末尾成员、右侧是组的首个成员时，分发到整组
+			lefts = span(groupStart[i], groupEnd[i])
+			rights = span(groupStart[i+1], groupEnd[i+1])
+		}
+		for _, l := range lefts {
+			for _, r := range rights {
+				g.edge(f.Tokens[l], f.Tokens[r], rule.Label, rule.Kind == Directed)
+			}
+		}
+	}
+	return nil
+}
+
+func span(from, to int) []int {
+	out := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func (g *Graph) node(id string) *Node {
+	if n, ok := g.nodes[id]; ok {
+		return n
+	}
+	n := &Node{ID: id}
+	g.nodes[id] = n
+	g.nodeOrder = append(g.nodeOrder, id)
+	return n
+}
+
+func (g *Graph) edge(from, to, label string, directed bool) {
+	// 无向边按字节序规范化端点，保证 A-B 与 B-A 合并
+	if !directed && from > to {
+		from, to = to, from
+	}
+	key := edgeKey{from: from, to: to, label: label, directed: directed}
+	if e, ok := g.edges[key]; ok {
+		e.Weight++
+		return
+	}
+	g.edges[key] = &Edge{From: from, To: to, Label: label, Directed: directed, Weight: 1}
+	g.edgeOrder = append(g.edgeOrder, key)
+}
+
+// Nodes 按首次出现顺序返回全部节点
+func (g *Graph) Nodes() []*Node {
+	out := make([]*Node, len(g.nodeOrder))
+	for i, id := range g.nodeOrder {
+		out[i] = g.nodes[id]
+	}
+	return out
+}
+
+// Edges 按首次出现顺序返回全部边
+func (g *Graph) Edges() []*Edge {
+	out := make([]*Edge, len(g.edgeOrder))
+	for i, key := range g.edgeOrder {
+		out[i] = g.edges[key]
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package cmlgraph_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/ContextMark/cml-go/cmlgraph"
+	"github.com/stretchr/testify/require"
+)
+
+// 投影与导出测试
+func TestProject(t *testing.T) {
+	ast := require.New(t)
+	frags := []*cml.CMLDouble{
+		cml.Build("微积分").Map("牛顿").Set("莱布尼茨").Remark("17世纪"),
+		cml.Build("万有引力").Map("牛顿"),
+		cml.Build("万有引力").Map("牛顿"),
+	}
+	g, err := cmlgraph.Project(frags, cmlgraph.DefaultRules())
+	ast.NoError(err)
+
+	ast.Len(g.Nodes(), 5)
+	ast.Equal(3, g.Nodes()[1].Count, "牛顿在三个片段中出现")
+
+	type edge struct {
+		from, to, label string
+		directed        bool
+		weight          int
+	}
+	var got []edge
+	for _, e := range g.Edges() {
+		got = append(got, edge{e.From, e.To, e.Label, e.Directed, e.Weight})
+	}
+	ast.Equal([]edge{
+		{"牛顿", "莱布尼茨", "set", false, 1},
+		{"微积分", "牛顿", "mapping", true, 1},
+		{"微积分", "莱布尼茨", "mapping", true, 1},
+		{"牛顿", "17世纪", "remark", true, 1},
+		{"莱布尼茨", "17世纪", "remark", true, 1},
+		{"万有引力", "牛顿", "mapping", true, 2},
+	}, got)
+
+	// 自定义规则：不分发、忽略备注
+	rules := cmlgraph.DefaultRules()
+	rules.DistributeOverSets = false
+	rules.ByRelation[cml.RelRemark] = cmlgraph.Rule{Kind: cmlgraph.Skip}
+	rules.ByRelation[cml.RelMapping] = cmlgraph.Rule{Kind: cmlgraph.Directed, Label: "discovered_by"}
+	g2, err := cmlgraph.Project(frags[:1], rules)
+	ast.NoError(err)
+	ast.Len(g2.Edges(), 2)
+	ast.Equal("discovered_by", g2.Edges()[1].Label)
+
+	var dot strings.Builder
+	ast.NoError(g.WriteDOT(&dot))
+	ast.Contains(dot.String(), `"万有引力" -> "牛顿" [label="mapping", weight=2];`)
+	ast.Contains(dot.String(), `"牛顿" -> "莱布尼茨" [label="set", dir=none];`)
+
+	var gml strings.Builder
+	ast.NoError(g.WriteGraphML(&gml))
+	ast.NoError(xml.Unmarshal([]byte(gml.String()), new(struct{})), "GraphML 应是合法的XML")
+	ast.Contains(gml.String(), `<data key="label">万有引力</data>`)
+
+	var cypher strings.Builder
+	ast.NoError(g.WriteCypher(&cypher))
+	ast.True(strings.HasPrefix(cypher.String(), "CREATE\n"))
+	ast.Contains(cypher.String(), `(n4:Token {name: "万有引力", count: 2})`)
+	ast.Contains(cypher.String(), `(n4)-[:MAPPING {weight: 2}]->(n1)`)
+	ast.Contains(cypher.String(), `(n1)-[:SET {weight: 1, undirected: true}]->(n2)`)
+
+	// 非ASCII和含特殊字符的标签用反引号保留原文
+	rules = cmlgraph.DefaultRules()
+	rules.ByRelation[cml.RelMapping] = cmlgraph.Rule{Kind: cmlgraph.Directed, Label: "属于"}
+	rules.ByRelation[cml.RelSet] = cmlgraph.Rule{Kind: cmlgraph.Directed, Label: "a`b c"}
+	g3, err := cmlgraph.Project([]*cml.CMLDouble{cml.Build("牛顿").Map("物理学家").Set("数学家")}, rules)
+	ast.NoError(err)
+	cypher.Reset()
+	ast.NoError(g3.WriteCypher(&cypher))
+	ast.Contains(cypher.String(), "[:`属于` {weight: 1}]")
+	ast.Contains(cypher.String(), "[:`a``b c` {weight: 1}]")
+
+	_, err = cmlgraph.Project([]*cml.CMLDouble{{}}, cmlgraph.DefaultRules())
+	ast.Error(err)
+}
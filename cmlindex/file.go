@@ -0,0 +1,223 @@
+package cmlindex
+
+/**
+索引文件格式(版本1)，所有整数除特别说明外均为 uvarint:
+
+	magic      6字节 "CMLIDX"
+	version    1字节，当前为 1
+	docCount   片段数量
+	docs       docCount 个 { len(id) id len(encoded) encoded }，下标即文档号
+	keyCount   索引键数量
+	postings   keyCount 个 { len(key) key n delta_1 ... delta_n }
+	           文档号升序，delta_1 为首个文档号，其余为与前一个文档号的差值
+	crc        4字节小端 CRC32(IEEE)，覆盖前面的全部字节
+
+写出时会压实已删除的空位并重新编号，键按字节序排列，相同内容的索引写出的文件完全一致。
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	fileMagic   = "CMLIDX"
+	fileVersion = 1
+)
+
+// WriteTo 将索引按文件格式写出
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// 压实：旧文档号 -> 新文档号
+	remap := make([]uint32, len(x.docs))
+	var buf []byte
+	buf = append(buf, fileMagic...)
+	buf = append(buf, fileVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(x.byID)))
+	next := uint32(0)
+	for num, d := range x.docs {
+		if d.deleted {
+			continue
+		}
+		remap[num] = next
+		next++
+		buf = appendString(buf, d.id)
+		buf = appendString(buf, d.encoded)
+	}
+
+	keys := make([]string, 0, len(x.postings))
+	for key := range x.postings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, key := range keys {
+		list := x.postings[key]
+		buf = appendString(buf, key)
+		buf = binary.AppendUvarint(buf, uint64(len(list)))
+		prev := uint32(0)
+		for _, num := range list {
+			buf = binary.AppendUvarint(buf, uint64(remap[num]-prev))
+			prev = remap[num]
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom 从文件格式读取索引，替换当前全部内容
+func (x *Index) ReadFrom(r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	n := int64(len(data))
+	if len(data) < len(fileMagic)+1+4 || string(data[:len(fileMagic)]) != fileMagic {
+		return n, fmt.Errorf("%w: 文件头不合法", errCorrupt)
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return n, fmt.Errorf("%w: CRC校验失败", errCorrupt)
+	}
+	if v := body[len(fileMagic)]; v != fileVersion {
+		return n, fmt.Errorf("cmlindex: 不支持的索引文件版本 %d", v)
+	}
+	rd := &byteReader{b: body[len(fileMagic)+1:]}
+
+	docCount := rd.uvarint()
+	if docCount > uint64(len(body)) {
+		return n, fmt.Errorf("%w: 片段数量不合法", errCorrupt)
+	}
+	docs := make([]doc, 0, docCount)
+	byID := make(map[string]uint32, docCount)
+	for i := uint64(0); i < docCount && rd.err == nil; i++ {
+		d := doc{id: rd.string(), encoded: rd.string()}
+		if _, dup := byID[d.id]; dup {
+			return n, fmt.Errorf("%w: 重复的片段ID %q", errCorrupt, d.id)
+		}
+		byID[d.id] = uint32(i)
+		docs = append(docs, d)
+	}
+
+	keyCount := rd.uvarint()
+	postings := make(map[string][]uint32)
+	for i := uint64(0); i < keyCount && rd.err == nil; i++ {
+		key := rd.string()
+		count := rd.uvarint()
+		if count == 0 || count > docCount {
+			return n, fmt.Errorf("%w: 倒排表长度不合法", errCorrupt)
+		}
+		list := make([]uint32, 0, count)
+		prev := uint64(0)
+		for j := uint64(0); j < count && rd.err == nil; j++ {
+			delta := rd.uvarint()
+			if j > 0 && delta == 0 {
+				return n, fmt.Errorf("%w: 倒排表不是严格升序", errCorrupt)
+			}
+			prev += delta
+			if prev >= docCount {
+				return n, fmt.Errorf("%w: 文档号越界", errCorrupt)
+			}
+			list = append(list, uint32(prev))
+		}
+		postings[key] = list
+	}
+	if rd.err != nil {
+		return n, fmt.Errorf("%w: %v", errCorrupt, rd.err)
+	}
+	if len(rd.b) != 0 {
+		return n, fmt.Errorf("%w: 文件尾部有多余数据", errCorrupt)
+	}
+
+	x.mu.Lock()
+	x.docs, x.byID, x.postings = docs, byID, postings
+	x.mu.Unlock()
+	return n, nil
+}
+
+// SaveFile 将索引原子地写入单个文件：先写临时文件再重命名
+func (x *Index) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if _, err := x.WriteTo(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile 从单个文件加载索引
+func LoadFile(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	x := New()
+	if _, err := x.ReadFrom(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// 顺序读取 uvarint 和带长度前缀的字符串，出错后后续读取均返回零值
+type byteReader struct {
+	b   []byte
+	err error
+}
+
+func (r *byteReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *byteReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(len(r.b)) {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
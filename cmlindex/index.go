@@ -0,0 +1,180 @@
+// Package cmlindex 为大规模CML片段语料提供倒排索引。
+//
+// 索引以外部ID接收CML编码字符串，对以下三类键建立倒排表:
+//  1. token：片段中出现过的token
+//  2. 关系位置：token作为某个关系符的左侧或右侧出现，以及片段使用过的关系符
+//  3. 三元组：左token-关系符-右token
+//
+// 查询由 Token、Left、Right、Triple、Rel 等原子条件经 And、Or 组合而成。
+// 索引支持增量添加与删除，并可以持久化为单个文件，格式见 WriteTo。
+package cmlindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// Index 倒排索引，可并发读写
+type Index struct {
+	mu       sync.RWMutex
+	docs     []doc               // 文档号 -> 文档，删除后留空位，持久化时压实
+	byID     map[string]uint32   // 外部ID -> 文档号
+	postings map[string][]uint32 // 索引键 -> 升序文档号
+}
+
+type doc struct {
+	id      string
+	encoded string
+	deleted bool
+}
+
+// New 创建空索引
+func New() *Index {
+	return &Index{
+		byID:     make(map[string]uint32),
+		postings: make(map[string][]uint32),
+	}
+}
+
+// Add 解码并索引一个片段，ID已存在时替换旧片段
+func (x *Index) Add(id, encoded string) error {
+	f, err := cml.CML2Fragments(encoded)
+	if err != nil {
+		return fmt.Errorf("索引片段 %q 失败: %w", id, err)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old, ok := x.byID[id]; ok {
+		x.remove(old)
+	}
+	num := uint32(len(x.docs))
+	x.docs = append(x.docs, doc{id: id, encoded: encoded})
+	x.byID[id] = num
+	// 新文档号总是最大的，直接追加即可保持倒排表升序
+	for _, key := range keysOf(f) {
+		x.postings[key] = append(x.postings[key], num)
+	}
+	return nil
+}
+
+// Delete 删除一个片段，ID不存在时返回 false
+func (x *Index) Delete(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	num, ok := x.byID[id]
+	if !ok {
+		return false
+	}
+	x.remove(num)
+	return true
+}
+
+// 从所有倒排表中移除文档号，调用方持有写锁
+func (x *Index) remove(num uint32) {
+	d := &x.docs[num]
+	// 入库时已解码成功，这里不会失败
+	f, _ := cml.CML2Fragments(d.encoded)
+	for _, key := range keysOf(f) {
+		list := x.postings[key]
+		if i, found := slices.BinarySearch(list, num); found {
+			list = slices.Delete(list, i, i+1)
+		}
+		if len(list) == 0 {
+			delete(x.postings, key)
+		} else {
+			x.postings[key] = list
+		}
+	}
+	delete(x.byID, d.id)
+	*d = doc{deleted: true}
+}
+
+// Get 返回ID对应的CML编码
+func (x *Index) Get(id string) (string, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	num, ok := x.byID[id]
+	if !ok {
+		return "", false
+	}
+	return x.docs[num].encoded, true
+}
+
+// Len 当前索引的片段数量
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.byID)
+}
+
+// Search 执行查询，按添加顺序返回命中片段的ID
+func (x *Index) Search(q Query) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	nums := q.eval(x)
+	ids := make([]string, 0, len(nums))
+	for _, num := range nums {
+		ids = append(ids, x.docs[num].id)
+	}
+	return ids
+}
+
+/**
+------------------------ 索引键 --------------------------
+键的首字节区分类别，token部分带长度前缀，避免任意内容的token之间拼接产生歧义
+*/
+
+const (
+	keyToken  = 't' // t + token
+	keyRel    = 'r' // r + 关系符
+	keyLeft   = 'L' // L + 关系符 + token，token出现在关系符左侧
+	keyRight  = 'R' // R + 关系符 + token，token出现在关系符右侧
+	keyTriple = 'x' // x + 关系符 + len(左) + 左 + 右
+)
+
+func tokenKey(token string) string {
+	return string(rune(keyToken)) + token
+}
+
+func relKey(rel cml.Relation) string {
+	return string([]byte{keyRel, rel.Symbol()})
+}
+
+func sideKey(kind byte, rel cml.Relation, token string) string {
+	return string([]byte{kind, rel.Symbol()}) + token
+}
+
+func tripleKey(left string, rel cml.Relation, right string) string {
+	b := []byte{keyTriple, rel.Symbol()}
+	b = binary.AppendUvarint(b, uint64(len(left)))
+	return string(b) + left + right
+}
+
+// 片段的全部索引键，已去重
+func keysOf(f *cml.CMLDouble) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	add := func(key string) {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	for token := range f.TokenSeq() {
+		add(tokenKey(token))
+	}
+	for tr := range f.Triples() {
+		add(relKey(tr.Rel))
+		add(sideKey(keyLeft, tr.Rel, tr.Left))
+		add(sideKey(keyRight, tr.Rel, tr.Right))
+		add(tripleKey(tr.Left, tr.Rel, tr.Right))
+	}
+	return keys
+}
+
+var errCorrupt = errors.New("cmlindex: 索引文件已损坏")
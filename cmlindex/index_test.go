@@ -0,0 +1,76 @@
+package cmlindex_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/ContextMark/cml-go/cmlindex"
+	"github.com/stretchr/testify/require"
+)
+
+func mustEncode(t *testing.T, f *cml.CMLDouble) string {
+	t.Helper()
+	s, err := f.EncodeC()
+	require.NoError(t, err)
+	return s
+}
+
+// 倒排索引测试：增量增删、组合查询与持久化
+func TestIndex(t *testing.T) {
+	ast := require.New(t)
+	x := cmlindex.New()
+
+	ast.NoError(x.Add("f1", mustEncode(t, cml.Build("万有引力").Map("牛顿").Remark("1687 年"))))
+	ast.NoError(x.Add("f2", mustEncode(t, cml.Build("微积分").Map("牛顿").Set("莱布尼茨"))))
+	ast.NoError(x.Add("f3", mustEncode(t, cml.Build("牛顿").Map("物理学家"))))
+	ast.Error(x.Add("bad", "INVALID"))
+	ast.Equal(3, x.Len())
+
+	ast.Equal([]string{"f1", "f2", "f3"}, x.Search(cmlindex.Token("牛顿")))
+	ast.Equal([]string{"f3"}, x.Search(cmlindex.Left("牛顿", cml.RelMapping)))
+	ast.Equal([]string{"f1", "f2"}, x.Search(cmlindex.Right("牛顿", cml.RelMapping)))
+	ast.Equal([]string{"f2"}, x.Search(cmlindex.Triple("牛顿", cml.RelSet, "莱布尼茨")))
+	ast.Equal([]string{"f1"}, x.Search(cmlindex.Rel(cml.RelRemark)))
+	ast.Equal([]string{"f1", "f2"}, x.Search(cmlindex.Or(cmlindex.Token("万有引力"), cmlindex.Token("微积分"))))
+	ast.Equal([]string{"f2"}, x.Search(cmlindex.And(cmlindex.Token("牛顿"), cmlindex.Rel(cml.RelSet))))
+	ast.Empty(x.Search(cmlindex.And()))
+
+	// 删除与替换
+	ast.True(x.Delete("f1"))
+	ast.False(x.Delete("f1"))
+	ast.Equal([]string{"f2", "f3"}, x.Search(cmlindex.Token("牛顿")))
+	ast.Empty(x.Search(cmlindex.Token("万有引力")))
+	ast.NoError(x.Add("f3", mustEncode(t, cml.Build("牛顿").Map("数学家"))))
+	ast.Empty(x.Search(cmlindex.Token("物理学家")))
+	ast.Equal([]string{"f3"}, x.Search(cmlindex.Token("数学家")))
+
+	// 持久化后查询结果一致，且可以继续增量操作
+	path := filepath.Join(t.TempDir(), "corpus.cmlidx")
+	ast.NoError(x.SaveFile(path))
+	loaded, err := cmlindex.LoadFile(path)
+	ast.NoError(err)
+	ast.Equal(2, loaded.Len())
+	ast.Equal([]string{"f2", "f3"}, loaded.Search(cmlindex.Token("牛顿")))
+	ast.True(loaded.Delete("f2"))
+	ast.Equal([]string{"f3"}, loaded.Search(cmlindex.Token("牛顿")))
+
+	// 相同内容写出的文件完全一致
+	var a, b bytes.Buffer
+	_, err = x.WriteTo(&a)
+	ast.NoError(err)
+	_, err = cmlindex.New().ReadFrom(bytes.NewReader(a.Bytes()))
+	ast.NoError(err)
+	reloaded, err := cmlindex.LoadFile(path)
+	ast.NoError(err)
+	_, err = reloaded.WriteTo(&b)
+	ast.NoError(err)
+	ast.Equal(a.Bytes(), b.Bytes())
+
+	// 损坏的文件被拒绝
+	corrupt := bytes.Clone(a.Bytes())
+	corrupt[len(corrupt)/2] ^= 0xff
+	_, err = cmlindex.New().ReadFrom(bytes.NewReader(corrupt))
+	ast.Error(err)
+}
@@ -0,0 +1,119 @@
+package cmlindex
+
+import (
+	"github.com/ContextMark/cml-go"
+)
+
+// Query 查询条件，由原子条件经 And、Or 组合
+type Query interface {
+	// 返回升序文档号，调用方持有读锁
+	eval(x *Index) []uint32
+}
+
+// 单个索引键的原子条件
+type termQuery string
+
+func (q termQuery) eval(x *Index) []uint32 {
+	return x.postings[string(q)]
+}
+
+// Token 片段中出现过该token
+func Token(token string) Query {
+	return termQuery(tokenKey(token))
+}
+
+// Rel 片段中使用过该关系符
+func Rel(rel cml.Relation) Query {
+	return termQuery(relKey(rel))
+}
+
+// Left token作为关系符 rel 的左侧出现，例如 Left("万有引力", cml.RelMapping)
+func Left(token string, rel cml.Relation) Query {
+	return termQuery(sideKey(keyLeft, rel, token))
+}
+
+// Right token作为关系符 rel 的右侧出现
+func Right(token string, rel cml.Relation) Query {
+	return termQuery(sideKey(keyRight, rel, token))
+}
+
+// Triple 片段中出现了 left rel right
+func Triple(left string, rel cml.Relation, right string) Query {
+	return termQuery(tripleKey(left, rel, right))
+}
+
+type andQuery []Query
+
+// And 同时满足全部条件，没有条件时不命中任何片段
+func And(qs ...Query) Query {
+	return andQuery(qs)
+}
+
+func (q andQuery) eval(x *Index) []uint32 {
+	if len(q) == 0 {
+		return nil
+	}
+	result := q[0].eval(x)
+	for _, sub := range q[1:] {
+		if len(result) == 0 {
+			return nil
+		}
+		result = intersect(result, sub.eval(x))
+	}
+	return result
+}
+
+type orQuery []Query
+
+// Or 满足任一条件
+func Or(qs ...Query) Query {
+	return orQuery(qs)
+}
+
+func (q orQuery) eval(x *Index) []uint32 {
+	var result []uint32
+	for _, sub := range q {
+		result = union(result, sub.eval(x))
+	}
+	return result
+}
+
+// 两个升序列表的交集
+func intersect(a, b []uint32) []uint32 {
+	var out []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// 两个升序列表的并集
+func union(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
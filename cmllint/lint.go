@@ -0,0 +1,219 @@
+// Package cmllint 检查格式正确但语义可疑的CML片段。
+//
+// 每条规则有唯一的名称和缺省级别，可以在配置中单独开关或调整级别。
+// 每个问题都附带修改建议，便于评审机器人直接给出反馈。
+package cmllint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// Severity 问题级别
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = [...]string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < Info || s > Error {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity 解析级别名称 info、warning 或 error
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("未知的级别: %q", name)
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	v, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// Finding 一个检查出的问题
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Index    int      `json:"index"` // 问题所在的token下标
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix"` // 修改建议
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s] token %d: %s（建议：%s）", f.Severity, f.Rule, f.Index, f.Message, f.Fix)
+}
+
+// Rule 一条检查规则
+type Rule struct {
+	Name        string
+	Description string
+	Severity    Severity // 缺省级别
+	check       func(f *cml.CMLDouble, cfg *Config) []Finding
+}
+
+// Rules 返回全部内置规则，按名称排序
+func Rules() []Rule {
+	rules := append([]Rule(nil), builtin...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+func lookup(name string) (Rule, bool) {
+	for _, r := range builtin {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// RuleConfig 单条规则的配置，字段为 nil 时使用缺省值
+type RuleConfig struct {
+	Enabled  *bool     `json:"enabled,omitempty"`
+	Severity *Severity `json:"severity,omitempty"`
+}
+
+// Config 检查配置
+//
+//	{
+//	  "maxTokenLength": 32,
+//	  "rules": {
+//	    "long-token": {"severity": "error"},
+//	    "mixed-punctuation": {"enabled": false}
+//	  }
+//	}
+type Config struct {
+	MaxTokenLength int                   `json:"maxTokenLength,omitempty"` // long-token 的字符数上限，缺省为 DefaultMaxTokenLength
+	Rules          map[string]RuleConfig `json:"rules,omitempty"`
+}
+
+// DefaultMaxTokenLength long-token 规则缺省的字符数上限
+const DefaultMaxTokenLength = 64
+
+// ParseConfig 解析 JSON 配置，拒绝未知的字段和规则名称
+func ParseConfig(data []byte) (*Config, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("cmllint: 解析配置失败: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadConfig 从 JSON 文件读取配置
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+func (c *Config) validate() error {
+	for name := range c.Rules {
+		if _, ok := lookup(name); !ok {
+			return fmt.Errorf("cmllint: 未知的规则: %q", name)
+		}
+	}
+	if c.MaxTokenLength < 0 {
+		return fmt.Errorf("cmllint: maxTokenLength 不能为负数: %d", c.MaxTokenLength)
+	}
+	return nil
+}
+
+func (c *Config) maxTokenLength() int {
+	if c.MaxTokenLength > 0 {
+		return c.MaxTokenLength
+	}
+	return DefaultMaxTokenLength
+}
+
+// Linter 按配置执行检查，可并发使用
+type Linter struct {
+	cfg   Config
+	rules []Rule // 启用的规则，级别已按配置调整
+}
+
+// New 创建检查器，cfg 为 nil 时启用全部规则并使用缺省级别
+func New(cfg *Config) (*Linter, error) {
+	l := &Linter{}
+	if cfg != nil {
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+		l.cfg = *cfg
+	}
+	for _, r := range Rules() {
+		rc := l.cfg.Rules[r.Name]
+		if rc.Enabled != nil && !*rc.Enabled {
+			continue
+		}
+		if rc.Severity != nil {
+			r.Severity = *rc.Severity
+		}
+		l.rules = append(l.rules, r)
+	}
+	return l, nil
+}
+
+// Lint 检查片段，结果按token下标和规则名称排序
+// 片段不满足交替规律时返回错误，不执行任何规则
+func (l *Linter) Lint(f *cml.CMLDouble) ([]Finding, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, r := range l.rules {
+		for _, fd := range r.check(f, &l.cfg) {
+			fd.Rule = r.Name
+			fd.Severity = r.Severity
+			findings = append(findings, fd)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Index != findings[j].Index {
+			return findings[i].Index < findings[j].Index
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+	return findings, nil
+}
+
+// LintEncoded 解码后检查，解码失败时返回错误
+// 待检查的片段视为不可信输入，按 cml.UntrustedDecodeOptions 的限制解码
+func (l *Linter) LintEncoded(encoded string) ([]Finding, error) {
+	f, err := cml.CML2FragmentsWith(encoded, cml.UntrustedDecodeOptions())
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(f)
+}
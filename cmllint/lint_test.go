@@ -0,0 +1,99 @@
+package cmllint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+)
+
+func rulesOf(findings []Finding) []string {
+	names := make([]string, len(findings))
+	for i, f := range findings {
+		names[i] = f.Rule + "@" + string(rune('0'+f.Index))
+	}
+	return names
+}
+
+func TestRules(t *testing.T) {
+	l, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		f    *cml.CMLDouble
+		want []string
+	}{
+		{"clean", cml.Build("万有引力").Map("牛顿").Remark("1687"), nil},
+		{"duplicate", cml.Build("牛顿").Set("莱布尼茨").Set("牛顿").Map("牛顿"), []string{"duplicate-set-member@2"}},
+		{"remark", cml.Build("A").Map("B").Remark("C").Map("D"), []string{"remark-in-chain@2"}},
+		{"blank", cml.Build("A").Line("　 ").Line("B"), []string{"blank-token@1"}},
+		{"long", cml.Build(strings.Repeat("长", DefaultMaxTokenLength+1)), []string{"long-token@0"}},
+		{"punct", cml.Build("你好,世界。").Line("3.14，圆周率"), []string{"mixed-punctuation@0"}},
+	}
+	for _, c := range cases {
+		fs, err := l.Lint(c.f)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := rulesOf(fs)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLintInvalid(t *testing.T) {
+	l, _ := New(nil)
+	f := &cml.CMLDouble{Tokens: []string{"牛顿", "莱布尼茨", "牛顿"}, Relations: []string{"+"}}
+	if _, err := l.Lint(f); err == nil {
+		t.Fatal("expected error for mismatched relations")
+	}
+}
+
+func TestFix(t *testing.T) {
+	l, _ := New(nil)
+	fs, _ := l.Lint(cml.Build("你好,世界。"))
+	if len(fs) != 1 || !strings.Contains(fs[0].Fix, "你好，世界。") || fs[0].Severity != Info {
+		t.Fatalf("findings = %v", fs)
+	}
+	fs, _ = l.Lint(cml.Build("Hello，world."))
+	if len(fs) != 1 || !strings.Contains(fs[0].Fix, "Hello,world.") {
+		t.Fatalf("findings = %v", fs)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"maxTokenLength": 4,
+		"rules": {
+			"long-token": {"severity": "error"},
+			"duplicate-set-member": {"enabled": false}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := l.LintEncoded("p牛顿+牛顿:自然哲学的数学原理")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 || fs[0].Rule != "long-token" || fs[0].Severity != Error || fs[0].Index != 2 {
+		t.Fatalf("findings = %v", fs)
+	}
+
+	if _, err := ParseConfig([]byte(`{"rules": {"no-such-rule": {}}}`)); err == nil {
+		t.Fatal("未知规则应当报错")
+	}
+	if _, err := ParseConfig([]byte(`{"rules": {"long-token": {"severity": "fatal"}}}`)); err == nil {
+		t.Fatal("未知级别应当报错")
+	}
+	if _, err := ParseConfig([]byte(`{"maxLen": 3}`)); err == nil {
+		t.Fatal("未知字段应当报错")
+	}
+}
@@ -0,0 +1,185 @@
+package cmllint
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// 内置规则
+var builtin = []Rule{
+	{
+		Name:        "duplicate-set-member",
+		Description: "+ 集合中出现重复成员",
+		Severity:    Warning,
+		check:       checkDuplicateSetMember,
+	},
+	{
+		Name:        "remark-in-chain",
+		Description: "@ 补充夹在 : 映射链中间，打断了映射关系",
+		Severity:    Warning,
+		check:       checkRemarkInChain,
+	},
+	{
+		Name:        "blank-token",
+		Description: "token 只包含空白字符",
+		Severity:    Error,
+		check:       checkBlankToken,
+	},
+	{
+		Name:        "long-token",
+		Description: "token 过长，通常说明应当拆分（嵌套CML除外）",
+		Severity:    Warning,
+		check:       checkLongToken,
+	},
+	{
+		Name:        "mixed-punctuation",
+		Description: "同一 token 中混用全角与半角标点",
+		Severity:    Info,
+		check:       checkMixedPunctuation,
+	},
+}
+
+// 以 + 连续连接的token构成一个集合，集合内的重复成员从第二次出现起报告
+func checkDuplicateSetMember(f *cml.CMLDouble, _ *Config) []Finding {
+	var findings []Finding
+	var seen map[string]bool
+	for i, tok := range f.Tokens {
+		if i == 0 || f.RelationAt(i-1) != cml.RelSet {
+			seen = map[string]bool{}
+		}
+		if seen[tok] {
+			findings = append(findings, Finding{
+				Index:   i,
+				Message: fmt.Sprintf("集合成员 %q 重复", tok),
+				Fix:     fmt.Sprintf("删除重复的成员 %q", tok),
+			})
+		}
+		seen[tok] = true
+	}
+	return findings
+}
+
+// 形如 A : B @ C : D 的片段，C 夹在映射链中间
+func checkRemarkInChain(f *cml.CMLDouble, _ *Config) []Finding {
+	var findings []Finding
+	rels := f.TypedRelations()
+	for j := 1; j+1 < len(rels); j++ {
+		if rels[j] == cml.RelRemark && rels[j-1] == cml.RelMapping && rels[j+1] == cml.RelMapping {
+			findings = append(findings, Finding{
+				Index:   j + 1,
+				Message: fmt.Sprintf("补充 %q 位于 : 映射链中间", f.Tokens[j+1]),
+				Fix:     "将补充移到映射链末尾，或改用 . 连接",
+			})
+		}
+	}
+	return findings
+}
+
+func checkBlankToken(f *cml.CMLDouble, _ *Config) []Finding {
+	var findings []Finding
+	for i, tok := range f.Tokens {
+		if strings.TrimSpace(tok) == "" {
+			findings = append(findings, Finding{
+				Index:   i,
+				Message: fmt.Sprintf("token %q 只包含空白字符", tok),
+				Fix:     "删除该 token 及其一侧的关系符",
+			})
+		}
+	}
+	return findings
+}
+
+func checkLongToken(f *cml.CMLDouble, cfg *Config) []Finding {
+	var findings []Finding
+	limit := cfg.maxTokenLength()
+	for i, tok := range f.Tokens {
+		if cml.IsNested(tok) {
+			continue
+		}
+		if n := utf8.RuneCountInString(tok); n > limit {
+			findings = append(findings, Finding{
+				Index:   i,
+				Message: fmt.Sprintf("token 长度 %d 超过上限 %d", n, limit),
+				Fix:     "拆分为多个 token，用 . 或 : 连接",
+			})
+		}
+	}
+	return findings
+}
+
+// 半角标点与对应的全角标点
+var punctPairs = [][2]rune{
+	{',', '，'}, {'.', '。'}, {':', '：'}, {';', '；'}, {'!', '！'},
+	{'?', '？'}, {'(', '（'}, {')', '）'}, {'[', '【'}, {']', '】'},
+}
+
+func toFullWidth(r rune) (rune, bool) {
+	for _, p := range punctPairs {
+		if p[0] == r {
+			return p[1], true
+		}
+	}
+	return r, false
+}
+
+func toHalfWidth(r rune) (rune, bool) {
+	for _, p := range punctPairs {
+		if p[1] == r {
+			return p[0], true
+		}
+	}
+	return r, false
+}
+
+// 同一 token 中同时出现全角与半角标点；数字之间的小数点不计入
+func checkMixedPunctuation(f *cml.CMLDouble, _ *Config) []Finding {
+	var findings []Finding
+	for i, tok := range f.Tokens {
+		if cml.IsNested(tok) {
+			continue
+		}
+		runes := []rune(tok)
+		var half, full, han bool
+		for k, r := range runes {
+			if _, ok := toFullWidth(r); ok && !isDecimalPoint(runes, k) {
+				half = true
+			}
+			if _, ok := toHalfWidth(r); ok {
+				full = true
+			}
+			if unicode.Is(unicode.Han, r) {
+				han = true
+			}
+		}
+		if !half || !full {
+			continue
+		}
+		// 含汉字时统一为全角，否则统一为半角
+		convert, width := toHalfWidth, "半角"
+		if han {
+			convert, width = toFullWidth, "全角"
+		}
+		fixed := make([]rune, len(runes))
+		for k, r := range runes {
+			fixed[k] = r
+			if !isDecimalPoint(runes, k) {
+				fixed[k], _ = convert(r)
+			}
+		}
+		findings = append(findings, Finding{
+			Index:   i,
+			Message: fmt.Sprintf("token %q 混用全角与半角标点", tok),
+			Fix:     fmt.Sprintf("统一为%s标点: %q", width, string(fixed)),
+		})
+	}
+	return findings
+}
+
+func isDecimalPoint(runes []rune, k int) bool {
+	return runes[k] == '.' && k > 0 && k+1 < len(runes) &&
+		runes[k-1] >= '0' && runes[k-1] <= '9' && runes[k+1] >= '0' && runes[k+1] <= '9'
+}
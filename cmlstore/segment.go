@@ -0,0 +1,204 @@
+package cmlstore
+
+/**
+段文件由连续的记录组成，每条记录:
+
+	length   4字节小端，body 的字节数
+	body     op(1字节) | uvarint len(key) | key | payload
+	crc      4字节小端 CRC32-C，覆盖 body
+
+op 为 1 表示写入，payload 是规范化编码；op 为 2 表示删除，payload 为空。
+扫描时遇到长度越界、CRC不符或残缺的记录：
+- 末尾段：视为崩溃时写了一半，截断到最后一条完整记录
+- 非末尾段：返回 ErrCorrupt，不做任何修改
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+)
+
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+
+	recordOverhead = 4 + 4 // 长度前缀 + CRC
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type record struct {
+	op      byte
+	key     string
+	payload string
+}
+
+type segment struct {
+	num      int
+	path     string
+	f        *os.File
+	size     int64
+	mu       sync.Mutex // 保护 refs 与 obsolete
+	refs     int        // 存储本身持有1，每个快照各持有1
+	obsolete bool       // 已被压缩替代，引用归零时删除文件
+}
+
+func openSegment(dir string, num int) (*segment, error) {
+	path := segmentPath(dir, num)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{num: num, path: path, f: f, refs: 1}, nil
+}
+
+func createSegment(dir string, num int) (*segment, error) {
+	path := segmentPath(dir, num)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// 目录项落盘，掉电后新段文件不会丢失
+	if err := syncDir(dir); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return &segment{num: num, path: path, f: f, refs: 1}, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// 顺序扫描全部记录并回调，last 表示是否为末尾段
+func (seg *segment) scan(last bool, fn func(record, location)) error {
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return err
+	}
+	off := 0
+	for off < len(data) {
+		rec, loc, n, ok := decodeRecord(data[off:])
+		if !ok {
+			if !last {
+				return fmt.Errorf("%w: %s 偏移 %d", ErrCorrupt, seg.path, off)
+			}
+			// 崩溃恢复：丢弃残缺的尾部
+			if err := seg.f.Truncate(int64(off)); err != nil {
+				return err
+			}
+			break
+		}
+		loc.seg = seg
+		loc.off += int64(off)
+		fn(rec, loc)
+		off += n
+	}
+	seg.size = int64(min(off, len(data)))
+	return nil
+}
+
+// 解析一条记录，返回记录、荷载在记录内的位置和记录总长度
+func decodeRecord(b []byte) (record, location, int, bool) {
+	if len(b) < recordOverhead {
+		return record{}, location{}, 0, false
+	}
+	bodyLen := int(binary.LittleEndian.Uint32(b))
+	if bodyLen < 2 || bodyLen > len(b)-recordOverhead {
+		return record{}, location{}, 0, false
+	}
+	body := b[4 : 4+bodyLen]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(b[4+bodyLen:]) {
+		return record{}, location{}, 0, false
+	}
+	op := body[0]
+	keyLen, n := binary.Uvarint(body[1:])
+	if n <= 0 || (op != opPut && op != opDelete) || keyLen > uint64(len(body)-1-n) {
+		return record{}, location{}, 0, false
+	}
+	payloadStart := 1 + n + int(keyLen)
+	rec := record{
+		op:      op,
+		key:     string(body[1+n : payloadStart]),
+		payload: string(body[payloadStart:]),
+	}
+	loc := location{off: int64(4 + payloadStart), n: len(body) - payloadStart}
+	return rec, loc, bodyLen + recordOverhead, true
+}
+
+// 追加一条记录，返回荷载位置
+func (seg *segment) write(rec record) (location, error) {
+	body := make([]byte, 0, 1+binary.MaxVarintLen64+len(rec.key)+len(rec.payload))
+	body = append(body, rec.op)
+	body = binary.AppendUvarint(body, uint64(len(rec.key)))
+	body = append(body, rec.key...)
+	payloadStart := len(body)
+	body = append(body, rec.payload...)
+
+	buf := make([]byte, 0, len(body)+recordOverhead)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(body)))
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(body, castagnoli))
+
+	off := seg.size
+	if _, err := seg.f.WriteAt(buf, off); err != nil {
+		return location{}, err
+	}
+	seg.size += int64(len(buf))
+	return location{seg: seg, off: off + 4 + int64(payloadStart), n: len(rec.payload)}, nil
+}
+
+// 读取荷载
+func (loc location) read() (string, error) {
+	buf := make([]byte, loc.n)
+	if _, err := loc.seg.f.ReadAt(buf, loc.off); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (seg *segment) acquire() {
+	seg.mu.Lock()
+	seg.refs++
+	seg.mu.Unlock()
+}
+
+// 释放一个引用，归零时关闭文件，已废弃的段同时删除文件
+func (seg *segment) release() error {
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	seg.refs--
+	if seg.refs > 0 {
+		return nil
+	}
+	err := seg.f.Close()
+	if seg.obsolete {
+		if rmErr := os.Remove(seg.path); err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// 标记为已被压缩替代并释放存储持有的引用
+func (seg *segment) retire() error {
+	seg.mu.Lock()
+	seg.obsolete = true
+	seg.mu.Unlock()
+	return seg.release()
+}
+
+// 丢弃写了一半的新段文件
+func (seg *segment) discard() {
+	seg.f.Close()
+	os.Remove(seg.path)
+}
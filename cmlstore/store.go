@@ -0,0 +1,425 @@
+// Package cmlstore 是本地、无外部依赖的CML片段持久化存储。
+//
+// 存储以用户键(key)和内容ID双重寻址：内容ID是规范化编码的 SHA-256，
+// 相同内容无论以哪种模式写入都得到同一个ID。
+//
+// 数据只追加写入目录下的段文件(segment)，每条记录带长度前缀和CRC，格式见 segment.go。
+// 打开时顺序扫描全部段文件重建内存索引；末尾段中因崩溃而写了一半的记录会被截断丢弃。
+// Compact 将存活记录重写到新的段文件，回收被覆盖和删除的空间。
+package cmlstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ContextMark/cml-go"
+)
+
+var (
+	// ErrNotFound 键或内容ID不存在
+	ErrNotFound = errors.New("cmlstore: 未找到")
+	// ErrClosed 存储已关闭
+	ErrClosed = errors.New("cmlstore: 存储已关闭")
+	// ErrCorrupt 非末尾段文件中出现损坏记录，无法自动恢复
+	ErrCorrupt = errors.New("cmlstore: 段文件已损坏")
+)
+
+// DefaultSegmentSize 缺省的段文件滚动阈值
+const DefaultSegmentSize = 64 << 20
+
+// Options 存储选项，nil 表示全部使用缺省值
+type Options struct {
+	SegmentSize int64 // 活动段超过该大小后滚动到新段文件
+	SyncWrites  bool  // 每次写入后 fsync，牺牲吞吐换取掉电安全
+}
+
+// Store 追加写存储，可并发读写
+type Store struct {
+	mu       sync.RWMutex
+	dir      string
+	opts     Options
+	segments []*segment          // 按编号升序，最后一个是活动段
+	keys     map[string]location // 用户键 -> 最新的写入记录
+	ids      map[string]location // 内容ID -> 任一存活记录
+	refs     map[string]int      // 内容ID -> 引用它的键数量
+	closed   bool
+}
+
+// 记录在段文件中的位置，off 指向荷载的首字节
+type location struct {
+	seg *segment
+	off int64
+	n   int
+	id  string
+}
+
+// Open 打开或创建存储目录，扫描段文件重建索引并完成崩溃恢复
+func Open(dir string, opts *Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:  dir,
+		keys: make(map[string]location),
+		ids:  make(map[string]location),
+		refs: make(map[string]int),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.SegmentSize <= 0 {
+		s.opts.SegmentSize = DefaultSegmentSize
+	}
+
+	nums, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i, num := range nums {
+		seg, err := openSegment(dir, num)
+		if err != nil {
+			s.closeSegments()
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+		if err := seg.scan(i == len(nums)-1, s.apply); err != nil {
+			s.closeSegments()
+			return nil, err
+		}
+	}
+	if len(s.segments) == 0 {
+		seg, err := createSegment(dir, 1)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+	}
+	return s, nil
+}
+
+// 将一条记录应用到内存索引
+func (s *Store) apply(rec record, loc location) {
+	if old, ok := s.keys[rec.key]; ok {
+		s.unref(old.id)
+		delete(s.keys, rec.key)
+	}
+	if rec.op == opDelete {
+		return
+	}
+	loc.id = ContentID(rec.payload)
+	s.keys[rec.key] = loc
+	s.ids[loc.id] = loc
+	s.refs[loc.id]++
+}
+
+func (s *Store) unref(id string) {
+	s.refs[id]--
+	if s.refs[id] <= 0 {
+		delete(s.refs, id)
+		delete(s.ids, id)
+	}
+}
+
+// ContentID 计算规范化编码的内容ID
+func ContentID(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// Canonical 将任意模式的CML编码转换为存储使用的规范化编码(p模式)
+func Canonical(encoded string) (string, error) {
+	return cml.CML2P(encoded)
+}
+
+// Put 写入一个片段，返回其内容ID；同一键再次写入会覆盖
+func (s *Store) Put(key, encoded string) (string, error) {
+	canonical, err := Canonical(encoded)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", ErrClosed
+	}
+	rec := record{op: opPut, key: key, payload: canonical}
+	loc, err := s.append(rec)
+	if err != nil {
+		return "", err
+	}
+	s.apply(rec, loc)
+	return s.keys[key].id, nil
+}
+
+// Delete 删除一个键，键不存在时返回 ErrNotFound
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if _, ok := s.keys[key]; !ok {
+		return ErrNotFound
+	}
+	rec := record{op: opDelete, key: key}
+	loc, err := s.append(rec)
+	if err != nil {
+		return err
+	}
+	s.apply(rec, loc)
+	return nil
+}
+
+// 追加到活动段，必要时先滚动到新段，调用方持有写锁
+func (s *Store) append(rec record) (location, error) {
+	active := s.segments[len(s.segments)-1]
+	if active.size >= s.opts.SegmentSize {
+		// 旧段滚动后不再是末尾段，残缺的尾部会让重新打开失败，必须先落盘
+		if err := active.f.Sync(); err != nil {
+			return location{}, err
+		}
+		seg, err := createSegment(s.dir, active.num+1)
+		if err != nil {
+			return location{}, err
+		}
+		s.segments = append(s.segments, seg)
+		active = seg
+	}
+	loc, err := active.write(rec)
+	if err != nil {
+		return location{}, err
+	}
+	if s.opts.SyncWrites {
+		if err := active.f.Sync(); err != nil {
+			return location{}, err
+		}
+	}
+	return loc, nil
+}
+
+// Get 按用户键读取规范化编码
+func (s *Store) Get(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return "", ErrClosed
+	}
+	loc, ok := s.keys[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return loc.read()
+}
+
+// GetByID 按内容ID读取规范化编码
+func (s *Store) GetByID(id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return "", ErrClosed
+	}
+	loc, ok := s.ids[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return loc.read()
+}
+
+// Len 存活的键数量
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Compact 将全部存活记录重写到新段文件，并移除旧段文件
+// 仍被快照引用的旧段文件会在快照关闭后删除
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	old := s.segments
+	seg, err := createSegment(s.dir, old[len(old)-1].num+1)
+	if err != nil {
+		return err
+	}
+	fresh := []*segment{seg}
+	// 任何一步失败都删除新段文件：残留的高编号段会在重新打开时最后重放，让旧值复活
+	committed := false
+	defer func() {
+		if !committed {
+			for _, f := range fresh {
+				f.discard()
+			}
+		}
+	}()
+	keys := make(map[string]location, len(s.keys))
+	for _, key := range sortedKeys(s.keys) {
+		loc := s.keys[key]
+		payload, err := loc.read()
+		if err == nil && seg.size >= s.opts.SegmentSize {
+			seg, err = createSegment(s.dir, seg.num+1)
+			if err == nil {
+				fresh = append(fresh, seg)
+			}
+		}
+		if err == nil {
+			loc, err = seg.write(record{op: opPut, key: key, payload: payload})
+		}
+		if err != nil {
+			return err
+		}
+		loc.id = s.keys[key].id
+		keys[key] = loc
+	}
+	for _, f := range fresh {
+		if err := f.f.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// 新段文件落盘后再切换索引，旧段文件标记为废弃
+	ids := make(map[string]location, len(s.ids))
+	for _, loc := range keys {
+		ids[loc.id] = loc
+	}
+	s.keys, s.ids, s.segments = keys, ids, fresh
+	committed = true
+	// 按编号升序删除旧段：中途崩溃时残留的都是较新的段，
+	// 其中的删除记录不会让更早的写入复活
+	var errs []error
+	for _, seg := range old {
+		errs = append(errs, seg.retire())
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭存储，未关闭的快照仍然可以继续读取
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.closeSegments()
+}
+
+func (s *Store) closeSegments() error {
+	var errs []error
+	for _, seg := range s.segments {
+		if seg.f != nil {
+			errs = append(errs, seg.f.Sync())
+		}
+		errs = append(errs, seg.release())
+	}
+	s.segments = nil
+	return errors.Join(errs...)
+}
+
+func sortedKeys(m map[string]location) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/**
+------------------------ 快照 --------------------------
+*/
+
+// Snapshot 某一时刻的只读视图，不受之后的写入、删除和压缩影响
+type Snapshot struct {
+	keys     []string
+	locs     map[string]location
+	segments []*segment
+}
+
+// Snapshot 创建当前状态的快照，用完后必须 Close 释放段文件
+func (s *Store) Snapshot() (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	snap := &Snapshot{
+		keys:     sortedKeys(s.keys),
+		locs:     make(map[string]location, len(s.keys)),
+		segments: slices.Clone(s.segments),
+	}
+	for k, v := range s.keys {
+		snap.locs[k] = v
+	}
+	for _, seg := range snap.segments {
+		seg.acquire()
+	}
+	return snap, nil
+}
+
+// Len 快照中的键数量
+func (snap *Snapshot) Len() int {
+	return len(snap.keys)
+}
+
+// All 按键的字节序遍历 (键, 规范化编码)，读取失败时提前结束
+// 返回的 errf 报告该遍历中的读取错误，完整遍历时为 nil；每次调用 All 各自独立，可并发遍历
+func (snap *Snapshot) All() (seq iter.Seq2[string, string], errf func() error) {
+	var err error
+	seq = func(yield func(string, string) bool) {
+		err = nil
+		for _, key := range snap.keys {
+			payload, rerr := snap.locs[key].read()
+			if rerr != nil {
+				err = fmt.Errorf("cmlstore: 读取键 %q 失败: %w", key, rerr)
+				return
+			}
+			if !yield(key, payload) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// Close 释放快照引用的段文件
+func (snap *Snapshot) Close() error {
+	var errs []error
+	for _, seg := range snap.segments {
+		errs = append(errs, seg.release())
+	}
+	snap.segments = nil
+	return errors.Join(errs...)
+}
+
+// 段文件命名：8位十进制编号 + .seg
+func segmentPath(dir string, num int) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d.seg", num))
+}
+
+func listSegments(dir string) ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, m := range matches {
+		var num int
+		if _, err := fmt.Sscanf(filepath.Base(m), "%08d.seg", &num); err == nil && num > 0 {
+			nums = append(nums, num)
+		}
+	}
+	slices.Sort(nums)
+	return nums, nil
+}
@@ -0,0 +1,175 @@
+package cmlstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/ContextMark/cml-go/cmlstore"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, f *cml.CMLDouble) string {
+	t.Helper()
+	s, err := f.EncodeA()
+	require.NoError(t, err)
+	return s
+}
+
+// 基本读写、内容ID去重与重新打开
+func TestStore_Basic(t *testing.T) {
+	ast := require.New(t)
+	dir := t.TempDir()
+
+	s, err := cmlstore.Open(dir, nil)
+	ast.NoError(err)
+
+	f := cml.Build("万有引力").Map("牛顿").Remark("1687 年")
+	idA, err := s.Put("k1", encode(t, f))
+	ast.NoError(err)
+	q, err := f.EncodeQ()
+	ast.NoError(err)
+	idQ, err := s.Put("k2", q)
+	ast.NoError(err)
+	ast.Equal(idA, idQ, "不同模式的相同内容应得到同一个内容ID")
+
+	p, err := f.EncodeP()
+	ast.NoError(err)
+	got, err := s.Get("k1")
+	ast.NoError(err)
+	ast.Equal(p, got)
+	got, err = s.GetByID(idA)
+	ast.NoError(err)
+	ast.Equal(p, got)
+
+	_, err = s.Put("bad", "INVALID")
+	ast.Error(err)
+	ast.NoError(s.Delete("k2"))
+	ast.ErrorIs(s.Delete("k2"), cmlstore.ErrNotFound)
+	_, err = s.Get("k2")
+	ast.ErrorIs(err, cmlstore.ErrNotFound)
+	ast.NoError(s.Close())
+
+	s, err = cmlstore.Open(dir, nil)
+	ast.NoError(err)
+	defer s.Close()
+	ast.Equal(1, s.Len())
+	got, err = s.Get("k1")
+	ast.NoError(err)
+	ast.Equal(p, got)
+}
+
+// 快照与压缩：快照不受之后的写入和压缩影响，压缩后数据不变且旧段被移除
+func TestStore_SnapshotCompact(t *testing.T) {
+	ast := require.New(t)
+	dir := t.TempDir()
+	s, err := cmlstore.Open(dir, &cmlstore.Options{SegmentSize: 256})
+	ast.NoError(err)
+	defer s.Close()
+
+	for i := range 20 {
+		_, err := s.Put(string(rune('a'+i%5)), encode(t, cml.Build("版本").Remark(string(rune('0'+i%10)))))
+		ast.NoError(err)
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	ast.Greater(len(segs), 1, "应按段大小滚动")
+
+	snap, err := s.Snapshot()
+	ast.NoError(err)
+	ast.NoError(s.Delete("a"))
+	ast.NoError(s.Compact())
+
+	keys := []string{}
+	all, errf := snap.All()
+	for key, payload := range all {
+		keys = append(keys, key)
+		ast.NoError(cml.IsCML(payload))
+	}
+	ast.Equal([]string{"a", "b", "c", "d", "e"}, keys)
+	ast.NoError(errf())
+	ast.NoError(snap.Close())
+
+	after, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	ast.NotContains(after, segs[0], "快照关闭后旧段文件应被删除")
+	ast.Equal(4, s.Len())
+	got, err := s.Get("e")
+	ast.NoError(err)
+	ast.Equal("p版本@9", got)
+}
+
+// 崩溃恢复：在末尾段的任意位置截断，都能恢复到最后一条完整记录
+func TestStore_CrashRecovery(t *testing.T) {
+	ast := require.New(t)
+	dir := t.TempDir()
+	s, err := cmlstore.Open(dir, nil)
+	ast.NoError(err)
+	_, err = s.Put("k1", encode(t, cml.Build("A").Map("B")))
+	ast.NoError(err)
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	info, err := os.Stat(segs[0])
+	ast.NoError(err)
+	firstEnd := info.Size()
+	_, err = s.Put("k2", encode(t, cml.Build("C").Map("D")))
+	ast.NoError(err)
+	ast.NoError(s.Close())
+
+	full, err := os.ReadFile(segs[0])
+	ast.NoError(err)
+	for cut := firstEnd; cut < int64(len(full)); cut++ {
+		ast.NoError(os.WriteFile(segs[0], full[:cut], 0o644))
+
+		s, err := cmlstore.Open(dir, nil)
+		ast.NoError(err, "截断位置 %d", cut)
+		ast.Equal(1, s.Len())
+		_, err = s.Get("k2")
+		ast.ErrorIs(err, cmlstore.ErrNotFound)
+
+		// 恢复后可以继续写入，且残缺尾部已被截掉
+		_, err = s.Put("k3", encode(t, cml.Build("E")))
+		ast.NoError(err)
+		ast.NoError(s.Close())
+		s, err = cmlstore.Open(dir, nil)
+		ast.NoError(err)
+		ast.Equal(2, s.Len())
+		ast.NoError(s.Close())
+	}
+
+	// 非末尾段损坏时拒绝打开
+	ast.NoError(os.WriteFile(segs[0], full[:len(full)-1], 0o644))
+	ast.NoError(os.WriteFile(filepath.Join(dir, "00000099.seg"), nil, 0o644))
+	_, err = cmlstore.Open(dir, nil)
+	ast.ErrorIs(err, cmlstore.ErrCorrupt)
+}
+
+// 读取失败：快照遍历通过 Err 报告不完整，压缩失败时不残留新段文件
+func TestStore_ReadFailure(t *testing.T) {
+	ast := require.New(t)
+	dir := t.TempDir()
+	s, err := cmlstore.Open(dir, nil)
+	ast.NoError(err)
+	defer s.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := s.Put(key, encode(t, cml.Build(key).Map("值")))
+		ast.NoError(err)
+	}
+	snap, err := s.Snapshot()
+	ast.NoError(err)
+	defer snap.Close()
+
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	ast.Len(segs, 1)
+	ast.NoError(os.Truncate(segs[0], 0))
+
+	n := 0
+	all, errf := snap.All()
+	for range all {
+		n++
+	}
+	ast.Zero(n)
+	ast.Error(errf())
+
+	ast.Error(s.Compact())
+	after, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	ast.Equal(segs, after, "压缩失败后应删除新写入的段文件")
+}
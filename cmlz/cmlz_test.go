@@ -0,0 +1,233 @@
+package cmlz_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/ContextMark/cml-go/cmlz"
+	"github.com/stretchr/testify/require"
+)
+
+// 构造token高度重复的语料
+func corpus(n int) []*cml.CMLDouble {
+	out := make([]*cml.CMLDouble, n)
+	for i := range n {
+		out[i] = cml.Build("万有引力").Map(fmt.Sprintf("作者%d", i%7)).Set("自然哲学的数学原理").Remark(fmt.Sprintf("%d 年", 1600+i%50))
+	}
+	return out
+}
+
+// 容器读写测试：压缩与非压缩两种方式，随机访问结果与写入一致
+func TestCMLZ_RoundTrip(t *testing.T) {
+	for _, deflate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("deflate=%v", deflate), func(t *testing.T) {
+			ast := require.New(t)
+			records := corpus(1000)
+
+			var buf bytes.Buffer
+			w, err := cmlz.NewWriter(&buf, &cmlz.WriterOptions{Deflate: deflate, BlockRecords: 64})
+			ast.NoError(err)
+			for _, f := range records {
+				ast.NoError(w.Write(f))
+			}
+			ast.NoError(w.Close())
+
+			r, err := cmlz.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			ast.NoError(err)
+			ast.Equal(len(records), r.Len())
+			ast.Equal(2+7+50, r.Tokens(), "重复token只进入字典一次")
+
+			for _, i := range []int{999, 0, 500, 63, 64, 1} {
+				got, err := r.Record(i)
+				ast.NoError(err)
+				ast.Equal(records[i], got)
+			}
+			_, err = r.Record(1000)
+			ast.Error(err)
+
+			i := 0
+			for f, err := range r.All() {
+				ast.NoError(err)
+				ast.Equal(records[i], f)
+				i++
+			}
+			ast.Equal(len(records), i)
+		})
+	}
+}
+
+// 压缩级别测试：0 即 flate.NoCompression，nil 为缺省级别，越界级别被拒绝
+func TestCMLZ_Level(t *testing.T) {
+	ast := require.New(t)
+	records := corpus(500)
+	write := func(level *int) []byte {
+		var buf bytes.Buffer
+		w, err := cmlz.NewWriter(&buf, &cmlz.WriterOptions{Deflate: true, Level: level})
+		ast.NoError(err)
+		for _, f := range records {
+			ast.NoError(w.Write(f))
+		}
+		ast.NoError(w.Close())
+		return buf.Bytes()
+	}
+	none := flate.NoCompression
+	stored, deflated := write(&none), write(nil)
+	ast.Greater(len(stored), len(deflated))
+
+	r, err := cmlz.NewReader(bytes.NewReader(stored), int64(len(stored)))
+	ast.NoError(err)
+	got, err := r.Record(499)
+	ast.NoError(err)
+	ast.Equal(records[499], got)
+
+	bad := 10
+	_, err = cmlz.NewWriter(io.Discard, &cmlz.WriterOptions{Deflate: true, Level: &bad})
+	ast.Error(err)
+}
+
+// JSONL 互转测试
+func TestCMLZ_JSONL(t *testing.T) {
+	ast := require.New(t)
+	var src strings.Builder
+	var want []string
+	for _, f := range corpus(100) {
+		p, err := f.EncodeP()
+		ast.NoError(err)
+		a, err := f.EncodeA()
+		ast.NoError(err)
+		want = append(want, p)
+		line, _ := json.Marshal(a)
+		src.Write(line)
+		src.WriteString("\n")
+	}
+
+	path := filepath.Join(t.TempDir(), "corpus.cmlz")
+	out, err := os.Create(path)
+	ast.NoError(err)
+	n, err := cmlz.FromJSONL(out, strings.NewReader(src.String()), &cmlz.WriterOptions{Deflate: true})
+	ast.NoError(err)
+	ast.Equal(100, n)
+	ast.NoError(out.Close())
+
+	r, err := cmlz.OpenFile(path)
+	ast.NoError(err)
+	defer r.Close()
+	var dst bytes.Buffer
+	ast.NoError(cmlz.ToJSONL(&dst, r, cml.ModeP))
+	lines := strings.Split(strings.TrimSpace(dst.String()), "\n")
+	ast.Len(lines, 100)
+	for i, line := range lines {
+		var got string
+		ast.NoError(json.Unmarshal([]byte(line), &got))
+		ast.Equal(want[i], got)
+	}
+
+	_, err = cmlz.FromJSONL(&bytes.Buffer{}, strings.NewReader("\"INVALID\"\n"), nil)
+	ast.Error(err)
+	_, err = cmlz.NewReader(bytes.NewReader([]byte("CMLZ")), 4)
+	ast.ErrorIs(err, cmlz.ErrCorrupt)
+}
+
+// 构造的损坏文件必须返回 ErrCorrupt 而不是 panic
+func TestCMLZ_Corrupt(t *testing.T) {
+	ast := require.New(t)
+	var buf bytes.Buffer
+	w, err := cmlz.NewWriter(&buf, &cmlz.WriterOptions{BlockRecords: 4})
+	ast.NoError(err)
+	for _, f := range corpus(10) {
+		ast.NoError(w.Write(f))
+	}
+	ast.NoError(w.Close())
+	valid := buf.Bytes()
+	le := binary.LittleEndian
+	footer := len(valid) - 44
+	indexOff := int(le.Uint64(valid[footer+16:]))
+
+	patch := func(fn func(b []byte)) []byte {
+		b := bytes.Clone(valid)
+		fn(b)
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "字典偏移溢出", data: patch(func(b []byte) {
+			le.PutUint64(b[footer:], 1<<63)
+			le.PutUint64(b[footer+8:], 1<<63)
+		})},
+		{name: "索引偏移溢出", data: patch(func(b []byte) { le.PutUint64(b[footer+16:], math.MaxUint64) })},
+		{name: "块偏移溢出", data: patch(func(b []byte) { le.PutUint64(b[indexOff:], math.MaxUint64-2) })},
+		{name: "非末块不满", data: patch(func(b []byte) {
+			// 第一块记为 2 条，最后一块补足总数
+			le.PutUint32(b[indexOff+12:], 2)
+			le.PutUint32(b[indexOff+2*16+12:], 4)
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cmlz.NewReader(bytes.NewReader(tt.data), int64(len(tt.data)))
+			require.ErrorIs(t, err, cmlz.ErrCorrupt)
+		})
+	}
+
+	// 压缩炸弹：解压后超过上限的块视为损坏
+	bomb := &bytes.Buffer{}
+	fw, err := flate.NewWriter(bomb, flate.BestCompression)
+	ast.NoError(err)
+	_, err = fw.Write(make([]byte, 65<<20))
+	ast.NoError(err)
+	ast.NoError(fw.Close())
+	buf.Reset()
+	w, err = cmlz.NewWriter(&buf, &cmlz.WriterOptions{Deflate: true})
+	ast.NoError(err)
+	ast.NoError(w.Write(cml.Build("A").Map("B")))
+	ast.NoError(w.Close())
+	file := bytes.Clone(buf.Bytes())
+	footer = len(file) - 44
+	indexOff = int(le.Uint64(file[footer+16:]))
+	blockOff, blockSize := int(le.Uint64(file[indexOff:])), int(le.Uint32(file[indexOff+8:]))
+	// 把炸弹插入第一块的位置，后续偏移整体后移
+	shift := uint64(bomb.Len() - blockSize)
+	file = append(file[:blockOff:blockOff], append(bomb.Bytes(), file[blockOff+blockSize:]...)...)
+	footer = len(file) - 44
+	indexOff += int(shift)
+	le.PutUint32(file[indexOff+8:], uint32(bomb.Len()))
+	le.PutUint64(file[footer:], le.Uint64(file[footer:])+shift)
+	le.PutUint64(file[footer+16:], le.Uint64(file[footer+16:])+shift)
+	r, err := cmlz.NewReader(bytes.NewReader(file), int64(len(file)))
+	ast.NoError(err)
+	_, err = r.Record(0)
+	ast.ErrorIs(err, cmlz.ErrCorrupt)
+}
+
+// 任意输入都不能让读取器 panic
+func FuzzCMLZ_Reader(f *testing.F) {
+	for _, deflate := range []bool{false, true} {
+		var buf bytes.Buffer
+		w, _ := cmlz.NewWriter(&buf, &cmlz.WriterOptions{Deflate: deflate, BlockRecords: 2})
+		for _, rec := range corpus(5) {
+			w.Write(rec)
+		}
+		w.Close()
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := cmlz.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		for range r.All() {
+		}
+	})
+}
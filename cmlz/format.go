@@ -0,0 +1,42 @@
+// Package cmlz 定义批量传输CML片段的语料容器格式 .cmlz，并提供读写实现。
+//
+// 大批量片段中的token高度重复，容器把所有token收进一张共享字典，
+// 每条记录只保存token编号和关系符序列，记录按块存放，块可以选择 DEFLATE 压缩。
+//
+// 文件布局(整数除特别说明外均为小端定长):
+//
+//	header     8字节: magic "CMLZ" | version(1字节，当前为1) | flags(1字节) | 保留(2字节)
+//	blocks     若干数据块，每块是连续记录的拼接，flags 的 bit0 置位时整块经 DEFLATE 压缩
+//	           记录: uvarint token数n | n 个 uvarint token编号 | n-1 个关系符字节
+//	dictionary 与数据块相同的压缩方式: uvarint 字典大小 | 每个token: uvarint 长度 + 原文
+//	index      每块16字节: u64 块偏移 | u32 存储长度 | u32 记录数
+//	footer     44字节: u64 字典偏移 | u64 字典存储长度 | u64 索引偏移 |
+//	           u32 块数 | u32 每块记录数 | u64 记录总数，最后4字节 magic "CMLZ"
+//
+// 除最后一块外每块记录数固定，按记录号随机访问时只需解码一个块。
+package cmlz
+
+import (
+	"errors"
+)
+
+const (
+	magic   = "CMLZ"
+	version = 1
+
+	flagDeflate = 1 << 0
+
+	headerSize     = 8
+	indexEntrySize = 16
+	footerSize     = 44
+
+	// DefaultBlockRecords 缺省的每块记录数
+	DefaultBlockRecords = 256
+
+	// 解压后的大小上限，防止压缩炸弹耗尽内存
+	maxBlockSize = 64 << 20
+	maxDictSize  = 1 << 30
+)
+
+// ErrCorrupt 容器文件结构损坏
+var ErrCorrupt = errors.New("cmlz: 容器文件已损坏")
@@ -0,0 +1,59 @@
+package cmlz
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// FromJSONL 将每行一个JSON字符串(CML编码)的 JSONL 转换为 .cmlz，空行被跳过，返回记录数
+func FromJSONL(dst io.Writer, src io.Reader, opts *WriterOptions) (int, error) {
+	w, err := NewWriter(dst, opts)
+	if err != nil {
+		return 0, err
+	}
+	sc := bufio.NewScanner(src)
+	sc.Buffer(make([]byte, 64<<10), 64<<20)
+	count, line := 0, 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var encoded string
+		if err := json.Unmarshal(sc.Bytes(), &encoded); err != nil {
+			return count, fmt.Errorf("cmlz: 第 %d 行不是JSON字符串: %w", line, err)
+		}
+		if err := w.WriteEncoded(encoded); err != nil {
+			return count, fmt.Errorf("cmlz: 第 %d 行: %w", line, err)
+		}
+		count++
+	}
+	if err := sc.Err(); err != nil {
+		return count, err
+	}
+	return count, w.Close()
+}
+
+// ToJSONL 将 .cmlz 的全部记录按指定模式编码，逐行写出JSON字符串
+func ToJSONL(dst io.Writer, r *Reader, mode uint8) error {
+	bw := bufio.NewWriter(dst)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	i := 0
+	for f, err := range r.All() {
+		if err != nil {
+			return err
+		}
+		encoded, err := f.Encode(mode)
+		if err != nil {
+			return fmt.Errorf("cmlz: 记录 %d: %w", i, err)
+		}
+		if err := enc.Encode(encoded); err != nil {
+			return err
+		}
+		i++
+	}
+	return bw.Flush()
+}
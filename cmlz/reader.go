@@ -0,0 +1,288 @@
+package cmlz
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// Reader 按记录号随机读取 .cmlz 容器，可并发使用
+type Reader struct {
+	r            io.ReaderAt
+	closer       io.Closer
+	deflate      bool
+	tokens       []string
+	blocks       []blockEntry
+	blockRecords int
+	records      int
+
+	mu     sync.Mutex
+	cached int // 缓存的块号，-1 表示无缓存
+	data   []byte
+	starts []int // 缓存块内每条记录的起始偏移
+}
+
+type blockEntry struct {
+	off     int64
+	size    uint32
+	records uint32
+}
+
+// NewReader 解析文件尾、索引和字典
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < headerSize+footerSize {
+		return nil, fmt.Errorf("%w: 文件过短", ErrCorrupt)
+	}
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != magic {
+		return nil, fmt.Errorf("%w: 文件头不合法", ErrCorrupt)
+	}
+	if header[4] != version {
+		return nil, fmt.Errorf("cmlz: 不支持的版本 %d", header[4])
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-footerSize); err != nil {
+		return nil, err
+	}
+	if string(footer[footerSize-4:]) != magic {
+		return nil, fmt.Errorf("%w: 文件尾不合法", ErrCorrupt)
+	}
+	le := binary.LittleEndian
+	dictOff, dictLen := le.Uint64(footer[0:]), le.Uint64(footer[8:])
+	indexOff := le.Uint64(footer[16:])
+	blockCount := uint64(le.Uint32(footer[24:]))
+	blockRecords := le.Uint32(footer[28:])
+	records := le.Uint64(footer[32:])
+
+	// 偏移与长度都来自文件内容，按 长度 > 上限 || 偏移 > 上限-长度 的方式比较以避免溢出
+	limit := uint64(size - footerSize)
+	if dictOff < headerSize || dictLen > limit || dictOff > limit-dictLen ||
+		indexOff > limit || limit-indexOff != blockCount*indexEntrySize || blockRecords == 0 {
+		return nil, fmt.Errorf("%w: 文件尾偏移不合法", ErrCorrupt)
+	}
+	cr := &Reader{
+		r:            r,
+		deflate:      header[5]&flagDeflate != 0,
+		blockRecords: int(blockRecords),
+		records:      int(records),
+		cached:       -1,
+	}
+
+	index := make([]byte, blockCount*indexEntrySize)
+	if _, err := r.ReadAt(index, int64(indexOff)); err != nil {
+		return nil, err
+	}
+	total := uint64(0)
+	for i := uint64(0); i < blockCount; i++ {
+		e := index[i*indexEntrySize:]
+		off, bsize := le.Uint64(e), le.Uint32(e[8:])
+		if off < headerSize || uint64(bsize) > dictOff || off > dictOff-uint64(bsize) {
+			return nil, fmt.Errorf("%w: 块 %d 的偏移不合法", ErrCorrupt, i)
+		}
+		b := blockEntry{off: int64(off), size: bsize, records: le.Uint32(e[12:])}
+		// 按记录号定位块依赖固定的每块记录数，只有最后一块可以不满
+		if b.records == 0 || b.records > blockRecords || (i+1 < blockCount && b.records != blockRecords) {
+			return nil, fmt.Errorf("%w: 块 %d 的记录数不合法", ErrCorrupt, i)
+		}
+		total += uint64(b.records)
+		cr.blocks = append(cr.blocks, b)
+	}
+	if total != records {
+		return nil, fmt.Errorf("%w: 记录总数与块索引不符", ErrCorrupt)
+	}
+
+	dict, err := cr.load(int64(dictOff), int64(dictLen), maxDictSize)
+	if err != nil {
+		return nil, err
+	}
+	rd := bytes.NewReader(dict)
+	count, err := binary.ReadUvarint(rd)
+	if err != nil || count > uint64(len(dict)) {
+		return nil, fmt.Errorf("%w: 字典不合法", ErrCorrupt)
+	}
+	cr.tokens = make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(rd)
+		if err != nil || n > uint64(rd.Len()) {
+			return nil, fmt.Errorf("%w: 字典不合法", ErrCorrupt)
+		}
+		var sb strings.Builder
+		sb.Grow(int(n))
+		io.CopyN(&sb, rd, int64(n))
+		cr.tokens = append(cr.tokens, sb.String())
+	}
+	return cr, nil
+}
+
+// OpenFile 打开 .cmlz 文件，用完后需要 Close
+func OpenFile(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	cr, err := NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	cr.closer = f
+	return cr, nil
+}
+
+// Close 关闭由 OpenFile 打开的文件
+func (cr *Reader) Close() error {
+	if cr.closer == nil {
+		return nil
+	}
+	return cr.closer.Close()
+}
+
+// Len 记录总数
+func (cr *Reader) Len() int {
+	return cr.records
+}
+
+// Tokens 共享字典中的token数量
+func (cr *Reader) Tokens() int {
+	return len(cr.tokens)
+}
+
+// Record 读取第 i 条记录
+func (cr *Reader) Record(i int) (*cml.CMLDouble, error) {
+	if i < 0 || i >= cr.records {
+		return nil, fmt.Errorf("cmlz: 记录号 %d 越界 [0, %d)", i, cr.records)
+	}
+	blockNum, k := i/cr.blockRecords, i%cr.blockRecords
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.cached != blockNum {
+		if err := cr.loadBlock(blockNum); err != nil {
+			return nil, err
+		}
+	}
+	return cr.decodeRecord(cr.data[cr.starts[k]:])
+}
+
+// All 按顺序遍历全部记录，出错时产出 (nil, err) 后结束
+func (cr *Reader) All() iter.Seq2[*cml.CMLDouble, error] {
+	return func(yield func(*cml.CMLDouble, error) bool) {
+		for i := 0; i < cr.records; i++ {
+			f, err := cr.Record(i)
+			if !yield(f, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 读取并解码一个块，记录每条记录的起始偏移，调用方持有锁
+func (cr *Reader) loadBlock(n int) error {
+	if n >= len(cr.blocks) {
+		return fmt.Errorf("%w: 块号越界", ErrCorrupt)
+	}
+	b := cr.blocks[n]
+	data, err := cr.load(b.off, int64(b.size), maxBlockSize)
+	if err != nil {
+		return err
+	}
+	starts := make([]int, 0, b.records)
+	off := 0
+	for range b.records {
+		if off >= len(data) {
+			return fmt.Errorf("%w: 块 %d 的记录数与数据不符", ErrCorrupt, n)
+		}
+		starts = append(starts, off)
+		size, err := recordSize(data[off:])
+		if err != nil {
+			return err
+		}
+		off += size
+	}
+	if off != len(data) {
+		return fmt.Errorf("%w: 块 %d 尾部有多余数据", ErrCorrupt, n)
+	}
+	cr.cached, cr.data, cr.starts = n, data, starts
+	return nil
+}
+
+// 读取一段存储数据，必要时解压，结果超过 max 字节视为损坏
+func (cr *Reader) load(off, size, max int64) ([]byte, error) {
+	if size > max {
+		return nil, fmt.Errorf("%w: 数据段超过 %d 字节", ErrCorrupt, max)
+	}
+	raw := make([]byte, size)
+	if _, err := cr.r.ReadAt(raw, off); err != nil {
+		return nil, err
+	}
+	if !cr.deflate {
+		return raw, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(raw)), max+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解压失败: %v", ErrCorrupt, err)
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w: 解压后超过 %d 字节", ErrCorrupt, max)
+	}
+	return data, nil
+}
+
+// 计算一条记录的字节长度
+func recordSize(b []byte) (int, error) {
+	n, off := binary.Uvarint(b)
+	if off <= 0 || n == 0 || n > uint64(len(b)) {
+		return 0, fmt.Errorf("%w: 记录头不合法", ErrCorrupt)
+	}
+	for range n {
+		_, size := binary.Uvarint(b[off:])
+		if size <= 0 {
+			return 0, fmt.Errorf("%w: token编号不合法", ErrCorrupt)
+		}
+		off += size
+	}
+	off += int(n) - 1
+	if off > len(b) {
+		return 0, fmt.Errorf("%w: 记录被截断", ErrCorrupt)
+	}
+	return off, nil
+}
+
+func (cr *Reader) decodeRecord(b []byte) (*cml.CMLDouble, error) {
+	n, off := binary.Uvarint(b)
+	f := &cml.CMLDouble{
+		Tokens:    make([]string, 0, n),
+		Relations: make([]string, 0, n-1),
+	}
+	for range n {
+		id, size := binary.Uvarint(b[off:])
+		if id >= uint64(len(cr.tokens)) {
+			return nil, fmt.Errorf("%w: token编号 %d 超出字典", ErrCorrupt, id)
+		}
+		f.Tokens = append(f.Tokens, cr.tokens[id])
+		off += size
+	}
+	for i := range int(n) - 1 {
+		rel, err := cml.ParseRelation(b[off+i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		f.Relations = append(f.Relations, rel.String())
+	}
+	return f, nil
+}
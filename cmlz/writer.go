@@ -0,0 +1,193 @@
+package cmlz
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ContextMark/cml-go"
+)
+
+// WriterOptions 写入选项，nil 表示不压缩、每块 DefaultBlockRecords 条记录
+type WriterOptions struct {
+	Deflate      bool // 数据块与字典使用 DEFLATE 压缩
+	Level        *int // 压缩级别，取值同 compress/flate，nil 表示 flate.DefaultCompression
+	BlockRecords int  // 每块记录数
+}
+
+// Writer 流式写入 .cmlz 容器，数据块写满即落盘，字典和索引在 Close 时写出
+type Writer struct {
+	w       io.Writer
+	opts    WriterOptions
+	level   int
+	off     uint64
+	dict    map[string]uint32
+	tokens  []string
+	block   bytes.Buffer
+	inBlock int
+	index   []byte
+	blocks  uint32
+	records uint64
+	closed  bool
+}
+
+// NewWriter 创建写入器并立即写出文件头
+func NewWriter(w io.Writer, opts *WriterOptions) (*Writer, error) {
+	cw := &Writer{w: w, dict: make(map[string]uint32)}
+	if opts != nil {
+		cw.opts = *opts
+	}
+	if cw.opts.BlockRecords <= 0 {
+		cw.opts.BlockRecords = DefaultBlockRecords
+	}
+	cw.level = flate.DefaultCompression
+	if cw.opts.Level != nil {
+		cw.level = *cw.opts.Level
+		if cw.level < flate.HuffmanOnly || cw.level > flate.BestCompression {
+			return nil, fmt.Errorf("cmlz: 非法的压缩级别 %d", cw.level)
+		}
+	}
+	header := []byte(magic)
+	var flags byte
+	if cw.opts.Deflate {
+		flags |= flagDeflate
+	}
+	header = append(header, version, flags, 0, 0)
+	if err := cw.emit(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write 追加一条记录
+func (cw *Writer) Write(f *cml.CMLDouble) error {
+	if cw.closed {
+		return errors.New("cmlz: 写入器已关闭")
+	}
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	var rec []byte
+	rec = binary.AppendUvarint(rec, uint64(len(f.Tokens)))
+	for _, token := range f.Tokens {
+		id, ok := cw.dict[token]
+		if !ok {
+			id = uint32(len(cw.tokens))
+			cw.dict[token] = id
+			cw.tokens = append(cw.tokens, token)
+		}
+		rec = binary.AppendUvarint(rec, uint64(id))
+	}
+	for i := range f.Relations {
+		rec = append(rec, f.RelationAt(i).Symbol())
+	}
+	cw.block.Write(rec)
+	cw.inBlock++
+	cw.records++
+	if cw.inBlock == cw.opts.BlockRecords {
+		return cw.flushBlock()
+	}
+	return nil
+}
+
+// WriteEncoded 解码并追加一条CML编码
+func (cw *Writer) WriteEncoded(encoded string) error {
+	f, err := cml.CML2Fragments(encoded)
+	if err != nil {
+		return err
+	}
+	return cw.Write(f)
+}
+
+// Close 写出剩余数据块、字典、索引和文件尾，不关闭底层写入器
+func (cw *Writer) Close() error {
+	if cw.closed {
+		return nil
+	}
+	cw.closed = true
+	if err := cw.flushBlock(); err != nil {
+		return err
+	}
+
+	var dict []byte
+	dict = binary.AppendUvarint(dict, uint64(len(cw.tokens)))
+	for _, token := range cw.tokens {
+		dict = binary.AppendUvarint(dict, uint64(len(token)))
+		dict = append(dict, token...)
+	}
+	if len(dict) > maxDictSize {
+		return fmt.Errorf("cmlz: 字典超过 %d 字节", maxDictSize)
+	}
+	dict, err := cw.compress(dict)
+	if err != nil {
+		return err
+	}
+	dictOff := cw.off
+	if err := cw.emit(dict); err != nil {
+		return err
+	}
+	indexOff := cw.off
+	if err := cw.emit(cw.index); err != nil {
+		return err
+	}
+
+	footer := make([]byte, 0, footerSize)
+	footer = binary.LittleEndian.AppendUint64(footer, dictOff)
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(dict)))
+	footer = binary.LittleEndian.AppendUint64(footer, indexOff)
+	footer = binary.LittleEndian.AppendUint32(footer, cw.blocks)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(cw.opts.BlockRecords))
+	footer = binary.LittleEndian.AppendUint64(footer, cw.records)
+	footer = append(footer, magic...)
+	return cw.emit(footer)
+}
+
+func (cw *Writer) flushBlock() error {
+	if cw.inBlock == 0 {
+		return nil
+	}
+	if cw.block.Len() > maxBlockSize {
+		return fmt.Errorf("cmlz: 数据块超过 %d 字节，请减小 BlockRecords", maxBlockSize)
+	}
+	data, err := cw.compress(cw.block.Bytes())
+	if err != nil {
+		return err
+	}
+	cw.index = binary.LittleEndian.AppendUint64(cw.index, cw.off)
+	cw.index = binary.LittleEndian.AppendUint32(cw.index, uint32(len(data)))
+	cw.index = binary.LittleEndian.AppendUint32(cw.index, uint32(cw.inBlock))
+	if err := cw.emit(data); err != nil {
+		return err
+	}
+	cw.blocks++
+	cw.block.Reset()
+	cw.inBlock = 0
+	return nil
+}
+
+func (cw *Writer) compress(data []byte) ([]byte, error) {
+	if !cw.opts.Deflate {
+		return bytes.Clone(data), nil
+	}
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, cw.level)
+	if err != nil {
+		return nil, fmt.Errorf("cmlz: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (cw *Writer) emit(b []byte) error {
+	n, err := cw.w.Write(b)
+	cw.off += uint64(n)
+	return err
+}
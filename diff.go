@@ -0,0 +1,297 @@
+package cml
+
+/**
+结构化差异与三方合并不列入语法内核:
+1、以 T R T R ... T 平铺后的基元为单位比较，token和关系符都是独立的编辑对象
+2、差异基于最长公共子序列(LCS)，时间和空间均为 O(n*m)，面向单个知识片段的规模
+3、补丁可以 JSON 序列化传输，应用时逐项校验基线内容，结果必须仍满足交替规律
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// EditKind 编辑操作类型
+type EditKind string
+
+const (
+	EditEqual  EditKind = "equal"  // 保留基线中连续 Count 个基元
+	EditDelete EditKind = "delete" // 删除基线中的一个基元
+	EditInsert EditKind = "insert" // 插入一个新基元
+)
+
+// 基元在补丁中的类别名称
+const (
+	kindToken    = "token"
+	kindRelation = "relation"
+)
+
+// EditOp 单个编辑操作
+type EditOp struct {
+	Op    EditKind `json:"op"`
+	Count int      `json:"n,omitempty"`     // equal 专用
+	Kind  string   `json:"kind,omitempty"`  // delete/insert 专用: token 或 relation
+	Index int      `json:"index"`           // token或关系符在各自序列中的索引，delete 指基线，insert 指结果，equal 为起始平铺索引
+	Value string   `json:"value,omitempty"` // delete/insert 专用
+	Hash  string   `json:"hash,omitempty"`  // equal 专用: 所保留基元的摘要
+}
+
+// Patch 从基线到目标的编辑脚本
+type Patch struct {
+	Ops []EditOp `json:"ops"`
+}
+
+// Conflict 三方合并中双方都修改了同一区域且结果不同
+type Conflict struct {
+	Offset int       // 冲突内容在合并结果中的起始平铺索引（合并结果中采用我方内容）
+	Base   []Element // 基线内容
+	Ours   []Element // 我方内容
+	Theirs []Element // 对方内容
+}
+
+// Diff 计算从 a 到 b 的编辑脚本
+func Diff(a, b *CMLDouble) (*Patch, error) {
+	if err := a.IsValid(); err != nil {
+		return nil, err
+	}
+	if err := b.IsValid(); err != nil {
+		return nil, err
+	}
+	ea, eb := flatten(a), flatten(b)
+	pairs := lcsPairs(ea, eb)
+
+	patch := &Patch{Ops: []EditOp{}}
+	i, j := 0, 0
+	emit := func(pi, pj int) {
+		for ; i < pi; i++ {
+			patch.Ops = append(patch.Ops, EditOp{Op: EditDelete, Kind: kindOf(ea[i]), Index: i / 2, Value: ea[i].Value})
+		}
+		for ; j < pj; j++ {
+			patch.Ops = append(patch.Ops, EditOp{Op: EditInsert, Kind: kindOf(eb[j]), Index: j / 2, Value: eb[j].Value})
+		}
+	}
+	for _, p := range pairs {
+		emit(p[0], p[1])
+		// 连续的相等基元合并为一个 equal 操作
+		if n := len(patch.Ops); n > 0 && patch.Ops[n-1].Op == EditEqual && patch.Ops[n-1].Index+patch.Ops[n-1].Count == i {
+			patch.Ops[n-1].Count++
+		} else {
+			patch.Ops = append(patch.Ops, EditOp{Op: EditEqual, Count: 1, Index: i})
+		}
+		i, j = i+1, j+1
+	}
+	emit(len(ea), len(eb))
+	for n := range patch.Ops {
+		if op := &patch.Ops[n]; op.Op == EditEqual {
+			op.Hash = digest(ea[op.Index : op.Index+op.Count])
+		}
+	}
+	return patch, nil
+}
+
+// Apply 将补丁应用到基线上，返回新的双序列，不修改基线
+// equal 和 delete 操作会校验基线内容，基线不符时返回错误
+func (p *Patch) Apply(base *CMLDouble) (*CMLDouble, error) {
+	if err := base.IsValid(); err != nil {
+		return nil, err
+	}
+	src := flatten(base)
+	out := make([]Element, 0, len(src))
+	cursor := 0
+	for n, op := range p.Ops {
+		switch op.Op {
+		case EditEqual:
+			if op.Count <= 0 || cursor+op.Count > len(src) {
+				return nil, fmt.Errorf("补丁应用失败: 第 %d 项 equal 超出基线范围", n)
+			}
+			if digest(src[cursor:cursor+op.Count]) != op.Hash {
+				return nil, fmt.Errorf("补丁应用失败: 第 %d 项 equal 与基线内容不符", n)
+			}
+			out = append(out, src[cursor:cursor+op.Count]...)
+			cursor += op.Count
+		case EditDelete:
+			if cursor >= len(src) || kindOf(src[cursor]) != op.Kind || src[cursor].Value != op.Value {
+				return nil, fmt.Errorf("补丁应用失败: 第 %d 项 delete 与基线内容不符", n)
+			}
+			cursor++
+		case EditInsert:
+			el, err := elementOf(op.Kind, op.Value)
+			if err != nil {
+				return nil, fmt.Errorf("补丁应用失败: 第 %d 项 insert: %w", n, err)
+			}
+			out = append(out, el)
+		default:
+			return nil, fmt.Errorf("补丁应用失败: 第 %d 项未知操作 %q", n, op.Op)
+		}
+	}
+	if cursor != len(src) {
+		return nil, fmt.Errorf("补丁应用失败: 基线剩余 %d 个基元未被补丁覆盖", len(src)-cursor)
+	}
+	return unflatten(out)
+}
+
+// digest 计算一段基元的摘要，类别和长度参与计算，避免拼接歧义
+func digest(els []Element) string {
+	h := sha256.New()
+	for _, el := range els {
+		fmt.Fprintf(h, "%s:%d:%s;", kindOf(el), len(el.Value), el.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Marshal 将补丁序列化为 JSON
+func (p *Patch) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// ParsePatch 从 JSON 反序列化补丁
+func ParsePatch(data []byte) (*Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("补丁反序列化失败: %w", err)
+	}
+	return &p, nil
+}
+
+// Merge3 以 base 为共同祖先，合并 ours 与 theirs 的修改
+// 双方冲突的区域采用我方内容，并在冲突列表中报告；合并结果不满足交替规律时返回错误
+func Merge3(base, ours, theirs *CMLDouble) (*CMLDouble, []Conflict, error) {
+	for _, f := range []*CMLDouble{base, ours, theirs} {
+		if err := f.IsValid(); err != nil {
+			return nil, nil, err
+		}
+	}
+	eb, eo, et := flatten(base), flatten(ours), flatten(theirs)
+	toOurs := matchIndex(len(eb), lcsPairs(eb, eo))
+	toTheirs := matchIndex(len(eb), lcsPairs(eb, et))
+
+	var merged []Element
+	var conflicts []Conflict
+	b0, o0, t0 := 0, 0, 0
+	for i := 0; i <= len(eb); i++ {
+		// 稳定点：基线基元在双方都被保留
+		if i < len(eb) && (toOurs[i] < 0 || toTheirs[i] < 0) {
+			continue
+		}
+		o1, t1 := len(eo), len(et)
+		if i < len(eb) {
+			o1, t1 = toOurs[i], toTheirs[i]
+		}
+		b, o, t := eb[b0:i], eo[o0:o1], et[t0:t1]
+		switch {
+		case slices.Equal(o, b):
+			merged = append(merged, t...)
+		case slices.Equal(t, b), slices.Equal(o, t):
+			merged = append(merged, o...)
+		default:
+			conflicts = append(conflicts, Conflict{
+				Offset: len(merged),
+				Base:   slices.Clone(b),
+				Ours:   slices.Clone(o),
+				Theirs: slices.Clone(t),
+			})
+			merged = append(merged, o...)
+		}
+		if i < len(eb) {
+			merged = append(merged, eb[i])
+			b0, o0, t0 = i+1, o1+1, t1+1
+		}
+	}
+	result, err := unflatten(merged)
+	if err != nil {
+		return nil, conflicts, fmt.Errorf("三方合并结果不合法: %w", err)
+	}
+	return result, conflicts, nil
+}
+
+/**
+------------------------ 辅助逻辑 --------------------------
+*/
+
+// 将双序列平铺成基元序列
+func flatten(f *CMLDouble) []Element {
+	out := make([]Element, 0, f.Len())
+	for _, el := range f.All() {
+		out = append(out, el)
+	}
+	return out
+}
+
+// 将平铺的基元序列还原为双序列，并校验交替规律
+func unflatten(els []Element) (*CMLDouble, error) {
+	if len(els) == 0 {
+		return nil, errors.New("CML基元序列不应为空")
+	}
+	single := make(CMLSingle, len(els))
+	for i := range els {
+		single[i] = &els[i]
+	}
+	return single.ToDouble()
+}
+
+func kindOf(el Element) string {
+	if el.Type == TypeToken {
+		return kindToken
+	}
+	return kindRelation
+}
+
+func elementOf(kind, value string) (Element, error) {
+	switch kind {
+	case kindToken:
+		return Element{Type: TypeToken, Value: value}, nil
+	case kindRelation:
+		return Element{Type: TypeSeparator, Value: value}, nil
+	}
+	return Element{}, fmt.Errorf("未知的基元类别 %q", kind)
+}
+
+// 经典动态规划求最长公共子序列，返回按顺序匹配的 (a索引, b索引)
+func lcsPairs(a, b []Element) [][2]int {
+	n, m := len(a), len(b)
+	// dp[i][j] 为 a[i:] 与 b[j:] 的LCS长度，倒序填表便于正序回溯
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// 基线索引 -> 另一方索引，未匹配为 -1
+func matchIndex(n int, pairs [][2]int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = -1
+	}
+	for _, p := range pairs {
+		idx[p[0]] = p[1]
+	}
+	return idx
+}
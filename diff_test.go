@@ -0,0 +1,83 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 差异与补丁测试：补丁经过序列化后应用到基线应得到目标
+func TestCML_DiffApply(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *cml.CMLDouble
+	}{
+		{"相同", cml.Build("A").Map("B"), cml.Build("A").Map("B")},
+		{"追加备注", cml.Build("万有引力").Map("牛顿"), cml.Build("万有引力").Map("牛顿").Remark("1687 年")},
+		{"替换token", cml.Build("A").Map("B").Set("C"), cml.Build("A").Map("X").Set("C")},
+		{"替换关系符", cml.Build("A").Map("B"), cml.Build("A").Set("B")},
+		{"完全不同", cml.Build("A"), cml.Build("X").Line("Y").Line("Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := require.New(t)
+			patch, err := cml.Diff(tt.a, tt.b)
+			ast.NoError(err)
+
+			data, err := patch.Marshal()
+			ast.NoError(err)
+			received, err := cml.ParsePatch(data)
+			ast.NoError(err)
+
+			got, err := received.Apply(tt.a)
+			ast.NoError(err)
+			ast.Equal(tt.b.Tokens, got.Tokens)
+			ast.Equal(tt.b.Relations, got.Relations)
+		})
+	}
+
+	t.Run("基线不符时拒绝应用", func(t *testing.T) {
+		patch, err := cml.Diff(cml.Build("A").Map("B"), cml.Build("A").Map("C"))
+		require.NoError(t, err)
+		_, err = patch.Apply(cml.Build("A").Map("X"))
+		require.Error(t, err)
+	})
+
+	t.Run("等长的不同基线", func(t *testing.T) {
+		patch, err := cml.Diff(cml.Build("A").Map("B"), cml.Build("A").Map("B").Set("C"))
+		require.NoError(t, err)
+		_, err = patch.Apply(cml.Build("X").Line("Y"))
+		require.Error(t, err)
+	})
+}
+
+// 三方合并测试
+func TestCML_Merge3(t *testing.T) {
+	ast := require.New(t)
+	base := cml.Build("万有引力").Map("牛顿")
+
+	// 双方修改不同区域，自动合并
+	ours := cml.Build("物理学").Line("万有引力").Map("牛顿")
+	theirs := cml.Build("万有引力").Map("牛顿").Remark("1687 年")
+	merged, conflicts, err := cml.Merge3(base, ours, theirs)
+	ast.NoError(err)
+	ast.Empty(conflicts)
+	ast.Equal(cml.Build("物理学").Line("万有引力").Map("牛顿").Remark("1687 年").Tokens, merged.Tokens)
+
+	// 双方修改同一token，报告冲突并采用我方
+	ours = cml.Build("万有引力").Map("Newton")
+	theirs = cml.Build("万有引力").Map("艾萨克·牛顿")
+	merged, conflicts, err = cml.Merge3(base, ours, theirs)
+	ast.NoError(err)
+	ast.Len(conflicts, 1)
+	ast.Equal("Newton", conflicts[0].Ours[0].Value)
+	ast.Equal("艾萨克·牛顿", conflicts[0].Theirs[0].Value)
+	ast.Equal(ours.Tokens, merged.Tokens)
+
+	// 双方做了相同的修改，不算冲突
+	merged, conflicts, err = cml.Merge3(base, ours, ours)
+	ast.NoError(err)
+	ast.Empty(conflicts)
+	ast.Equal(ours.Tokens, merged.Tokens)
+}
@@ -0,0 +1,60 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 原地编辑测试：每一步之后序列都必须保持合法
+func TestCML_Edit(t *testing.T) {
+	ast := require.New(t)
+
+	f := cml.Build("万有引力").Map("牛顿")
+
+	ast.NoError(f.Append(cml.RelRemark, "1687 年"))
+	ast.NoError(f.InsertAt(2, "自然哲学的数学原理", cml.RelRemark))
+	ast.NoError(f.ReplaceRelation(1, cml.RelSet))
+	ast.Equal(cml.Build("万有引力").Map("牛顿").Set("自然哲学的数学原理").Remark("1687 年"), f)
+
+	ast.NoError(f.Prepend("物理学", cml.RelLinear))
+	ast.Equal([]string{"物理学", "万有引力", "牛顿", "自然哲学的数学原理", "1687 年"}, f.Tokens)
+	ast.Equal([]string{".", ":", "+", "@"}, f.Relations)
+
+	// 删除末尾token时删除左侧关系符，其余位置删除右侧关系符
+	ast.NoError(f.RemoveAt(4))
+	ast.Equal([]string{".", ":", "+"}, f.Relations)
+	ast.NoError(f.RemoveAt(0))
+	ast.Equal([]string{":", "+"}, f.Relations)
+	ast.NoError(f.ReplaceToken(1, "Newton"))
+	ast.NoError(f.IsValid())
+
+	sub, err := f.Slice(1, 3)
+	ast.NoError(err)
+	ast.Equal(cml.Build("Newton").Set("自然哲学的数学原理"), sub)
+	sub.Tokens[0] = "changed"
+	ast.Equal("Newton", f.Tokens[1], "Slice 不应共享底层数组")
+
+	joined, err := cml.Build("A").Concat(cml.Build("B").Line("C"), cml.RelMapping)
+	ast.NoError(err)
+	ast.Equal(cml.Build("A").Map("B").Line("C"), joined)
+
+	// 非法编辑返回错误，且不修改原序列
+	before := cml.Build("A").Map("B")
+	errCases := []func(f *cml.CMLDouble) error{
+		func(f *cml.CMLDouble) error { return f.Append(cml.RelSet, "") },
+		func(f *cml.CMLDouble) error { return f.Append(cml.Relation('x'), "C") },
+		func(f *cml.CMLDouble) error { return f.InsertAt(3, "C", cml.RelSet) },
+		func(f *cml.CMLDouble) error { return f.RemoveAt(2) },
+		func(f *cml.CMLDouble) error { return f.ReplaceRelation(1, cml.RelSet) },
+		func(f *cml.CMLDouble) error { _, err := f.Slice(1, 1); return err },
+		func(f *cml.CMLDouble) error { _, err := f.Concat(&cml.CMLDouble{}, cml.RelSet); return err },
+	}
+	for _, fn := range errCases {
+		f := cml.Build("A").Map("B")
+		ast.Error(fn(f))
+		ast.Equal(before, f)
+	}
+	ast.Error(cml.Build("A").RemoveAt(0), "不能删除唯一的token")
+}
@@ -0,0 +1,156 @@
+package cml
+
+/**
+在任意文本(日志、大模型输出、网页)中查找嵌入的CML:
+1、按各模式的字符集规则线性扫描出候选子串，每个字节只查一次分类表，适合处理大量日志
+2、候选必须从词边界开始，即前一个字节不属于该模式的字符集
+3、p 模式候选不含空白和全角标点，因此文本中以空格表示的组合关系无法识别；末尾的关系符视为标点被去除
+4、只保留能够解码的候选，缺省使用严格模式和不可信输入的限制，过滤偶然符合字符集的普通单词
+5、候选被拒绝后，同一字符集连续段内的后续起点不再尝试，避免中文与字母交错的文本被反复扫描和解码
+*/
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FindOptions 查找选项，nil 表示全部使用缺省值
+type FindOptions struct {
+	MinLength int            // 候选的最小字节数(含模式前缀)，缺省为 DefaultFindMinLength
+	MinTokens int            // 解码后的最少token数，缺省为 DefaultFindMinTokens
+	Modes     string         // 允许的模式，例如 "ac"，缺省为全部4种模式
+	Decode    *DecodeOptions // 候选的解码选项，缺省为开启 Strict 的 UntrustedDecodeOptions
+}
+
+// FindAll 的缺省值：普通单词常以模式字母开头，单个token的候选误报率很高
+const (
+	DefaultFindMinLength = 8
+	DefaultFindMinTokens = 2
+)
+
+// Span 文本中找到的一段CML
+type Span struct {
+	Start, End int    // 在文本中的字节区间 [Start, End)
+	Encoded    string // text[Start:End]
+	Fragments  *CMLDouble
+}
+
+// 字符集分类，按位组合
+const (
+	classBase58    = 1 << iota // a 模式
+	classBase64                // c、q 模式
+	classPlaintext             // p 模式
+)
+
+// 字节 -> 所属字符集
+var findClasses = func() (t [256]uint8) {
+	const base58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	const base64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	for i := 0; i < len(base58); i++ {
+		t[base58[i]] |= classBase58
+	}
+	for i := 0; i < len(base64); i++ {
+		t[base64[i]] |= classBase64
+	}
+	// p 模式：除空白、控制字符和常见的包围符号外的 ASCII，以及全部多字节字符的字节
+	for b := 0x21; b < 0x7f; b++ {
+		if !strings.ContainsRune("\"'`<>()[]{},;=|\\", rune(b)) {
+			t[b] |= classPlaintext
+		}
+	}
+	for b := 0x80; b < 0x100; b++ {
+		t[b] |= classPlaintext
+	}
+	return t
+}()
+
+func modeClass(mode byte) uint8 {
+	switch mode {
+	case ModeA:
+		return classBase58
+	case ModeC, ModeQ:
+		return classBase64
+	case ModeP:
+		return classPlaintext
+	}
+	return 0
+}
+
+// FindAll 查找文本中全部可以解码的CML子串，按出现顺序返回，互不重叠
+func FindAll(text string, opts *FindOptions) []Span {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	minLen := opts.MinLength
+	if minLen <= 0 {
+		minLen = DefaultFindMinLength
+	}
+	minLen = max(minLen, 2)
+	minTokens := opts.MinTokens
+	if minTokens <= 0 {
+		minTokens = DefaultFindMinTokens
+	}
+	modes := opts.Modes
+	if modes == "" {
+		modes = "acqp"
+	}
+	var allowed [256]bool
+	for i := 0; i < len(modes); i++ {
+		allowed[modes[i]] = true
+	}
+	decode := opts.Decode
+	if decode == nil {
+		decode = UntrustedDecodeOptions()
+		decode.Strict = true
+	}
+
+	var spans []Span
+	var rejected [classPlaintext + 1]int // 各字符集最近一个被拒绝候选的连续段末尾
+	for i := 0; i < len(text); i++ {
+		mode := text[i]
+		if !allowed[mode] {
+			continue
+		}
+		class := modeClass(mode)
+		if i < rejected[class] {
+			continue
+		}
+		// 词边界：p 模式之前允许多字节字符，便于识别中文句子中的CML
+		if i > 0 {
+			prev := text[i-1]
+			if findClasses[prev]&class != 0 && !(mode == ModeP && prev >= 0x80) {
+				continue
+			}
+		}
+		end := i + 1
+		for end < len(text) && findClasses[text[end]]&class != 0 {
+			if text[end] < utf8.RuneSelf {
+				end++
+				continue
+			}
+			// 多字节字符中的空白和标点(如全角句号)结束 p 模式候选
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if unicode.IsSpace(r) || unicode.IsPunct(r) {
+				break
+			}
+			end += size
+		}
+		rejected[class] = end
+		// 末尾的关系符多半是句末标点
+		for end > i+1 && Relation(text[end-1]).IsValid() {
+			end--
+		}
+		if end-i < minLen {
+			continue
+		}
+		encoded := text[i:end]
+		f, err := CML2FragmentsWith(encoded, decode)
+		if err != nil || len(f.Tokens) < minTokens {
+			continue
+		}
+		spans = append(spans, Span{Start: i, End: end, Encoded: encoded, Fragments: f})
+		i = end - 1
+	}
+	return spans
+}
@@ -0,0 +1,73 @@
+package cml_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 文本查找测试：在日志和中文句子中找出各模式的CML，普通单词不被误报
+func TestCML_FindAll(t *testing.T) {
+	ast := require.New(t)
+	f := cml.Build("万有引力").Map("牛顿").Remark("1687")
+	a, _ := f.EncodeA()
+	c, _ := f.EncodeC()
+	q, _ := f.EncodeQ()
+	p, _ := f.EncodeP()
+
+	text := fmt.Sprintf("2026-10-18 INFO parsed cml=%s ok\n"+
+		"apple cat people python quickly capability\n"+
+		"<span data-cml=\"%s\">(%s)</span>\n"+
+		"我们用%s。", a, c, q, p)
+
+	spans := cml.FindAll(text, nil)
+	ast.Len(spans, 4)
+	for i, want := range []string{a, c, q, p} {
+		s := spans[i]
+		ast.Equal(want, s.Encoded)
+		ast.Equal(want, text[s.Start:s.End])
+		ast.Equal(f.Tokens, s.Fragments.Tokens)
+	}
+
+	// 模式过滤
+	spans = cml.FindAll(text, &cml.FindOptions{Modes: "cq"})
+	ast.Len(spans, 2)
+	ast.Equal(c, spans[0].Encoded)
+
+	// 最少token数与最小长度
+	ast.Empty(cml.FindAll("see pA:B here", nil))
+	ast.Len(cml.FindAll("see pA:B here", &cml.FindOptions{MinLength: 2}), 1)
+	ast.Empty(cml.FindAll("word pSingleToken here", nil))
+	ast.Len(cml.FindAll("word pSingleToken here", &cml.FindOptions{MinTokens: 1}), 1)
+
+	// 句末标点不属于CML，词中间的模式字母不是起点
+	spans = cml.FindAll("结论是 pGravity:Newton. 以及 xpGravity:Newton", nil)
+	ast.Len(spans, 1)
+	ast.Equal("pGravity:Newton", spans[0].Encoded)
+}
+
+func BenchmarkCML_FindAll(b *testing.B) {
+	encoded, _ := cml.Build("万有引力").Map("牛顿").Remark("1687").EncodeC()
+	line := "2026-10-18T12:00:00Z INFO request path=/api/v1/items user=alice latency=12ms cml=" + encoded + " status=200\n"
+	text := strings.Repeat(line, 10000)
+	b.SetBytes(int64(len(text)))
+	for b.Loop() {
+		if n := len(cml.FindAll(text, nil)); n != 10000 {
+			b.Fatalf("找到 %d 个", n)
+		}
+	}
+}
+
+// 中文与模式字母交错的文本：每个多字节字符后的 p 都是候选起点，扫描不能退化为平方级
+func BenchmarkCML_FindAllAdversarial(b *testing.B) {
+	text := strings.Repeat("中p", 1<<16)
+	b.SetBytes(int64(len(text)))
+	for b.Loop() {
+		if n := len(cml.FindAll(text, nil)); n != 0 {
+			b.Fatalf("找到 %d 个", n)
+		}
+	}
+}
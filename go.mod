@@ -2,11 +2,13 @@ module github.com/ContextMark/cml-go
 
 go 1.25.3
 
-require github.com/shengdoushi/base58 v1.0.0
+require (
+	github.com/shengdoushi/base58 v1.0.0
+	github.com/stretchr/testify v1.11.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
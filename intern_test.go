@@ -0,0 +1,67 @@
+package cml_test
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// token驻留测试：重复token共享存储，统计正确，并发安全
+func TestCML_Interner(t *testing.T) {
+	ast := require.New(t)
+	in := cml.NewInterner()
+	opts := &cml.DecodeOptions{Interner: in}
+
+	encoded, err := cml.Build("牛顿").Map("万有引力").Remark("牛顿").EncodeP()
+	ast.NoError(err)
+
+	var wg sync.WaitGroup
+	results := make([]*cml.CMLDouble, 16)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := cml.CML2FragmentsWith(encoded, opts)
+			if err == nil {
+				results[i] = f
+			}
+		}()
+	}
+	wg.Wait()
+
+	first := results[0]
+	ast.NotNil(first)
+	for _, f := range results {
+		ast.Equal(first, f)
+		ast.Equal(unsafe.StringData(first.Tokens[0]), unsafe.StringData(f.Tokens[0]), "相同token应共享存储")
+		ast.Equal(unsafe.StringData(f.Tokens[0]), unsafe.StringData(f.Tokens[2]))
+	}
+
+	stats := in.Stats()
+	ast.Equal(2, stats.Unique)
+	ast.Equal(uint64(2), stats.Misses)
+	ast.Equal(uint64(16*3-2), stats.Hits)
+	ast.Greater(stats.HitRate(), 0.9)
+	ast.Positive(stats.BytesSaved)
+
+	single, err := cml.CML2ElementsWith(encoded, opts)
+	ast.NoError(err)
+	ast.Equal(unsafe.StringData(first.Tokens[1]), unsafe.StringData((*single)[2].Value))
+
+	// 编号表示往返
+	idf, err := in.ToIDs(first)
+	ast.NoError(err)
+	ast.Equal([]uint32{in.ID("牛顿"), in.ID("万有引力"), in.ID("牛顿")}, idf.TokenIDs)
+	ast.Equal([]cml.Relation{cml.RelMapping, cml.RelRemark}, idf.Relations)
+	back, err := in.FromIDs(idf)
+	ast.NoError(err)
+	ast.Equal(first, back)
+
+	_, err = in.FromIDs(&cml.IDFragments{TokenIDs: []uint32{99}})
+	ast.Error(err)
+	_, err = in.FromIDs(&cml.IDFragments{TokenIDs: []uint32{0}, Relations: []cml.Relation{cml.RelSet}})
+	ast.Error(err, "数量不匹配")
+}
@@ -0,0 +1,234 @@
+package internal
+
+/**
+--- Base58 编解码(比特币字母表) ---
+输出与 github.com/shengdoushi/base58 逐字节一致：前导零字节编码为 '1'，其余部分按大整数转换。
+1、小输入使用字长分组运算：以 58^5 为基的 uint32 分组，每次吸收 4 个输入字节，
+   比逐字节、逐位的朴素实现少一个数量级的乘除运算
+2、大输入改用 math/big：编码使用其分治进制转换，解码按位数二分后以 Karatsuba 乘法合并，复杂度低于平方
+   分治解码的每一层检查 Context，取消后不再继续合并
+*/
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+const b58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// 58^5，小于 2^30，一个 uint32 分组容纳 5 位 Base58 数字
+const b58Radix5 = 58 * 58 * 58 * 58 * 58
+
+// 超过该字节数(编码)或位数(解码)时使用 math/big
+const (
+	b58BigEncodeBytes  = 2 << 10
+	b58BigDecodeDigits = 2 << 10
+)
+
+var errInvalidBase58 = errors.New("invalid base58 string")
+
+// 字符 -> 数值，非法字符为 -1
+var b58DecodeTable = func() (t [256]int8) {
+	for i := range t {
+		t[i] = -1
+	}
+	for i := 0; i < len(b58Alphabet); i++ {
+		t[b58Alphabet[i]] = int8(i)
+	}
+	return t
+}()
+
+// math/big 的 Text(58) 使用 0-9a-zA-V 作为数字，映射到比特币字母表
+var b58FromBigDigit = func() (t [256]byte) {
+	const bigDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	for i := 0; i < 58; i++ {
+		t[bigDigits[i]] = b58Alphabet[i]
+	}
+	return t
+}()
+
+// base58Encode 将字节编码为 Base58
+func base58Encode(src []byte) string {
+	zeros := 0
+	for zeros < len(src) && src[zeros] == 0 {
+		zeros++
+	}
+	rest := src[zeros:]
+
+	var digits []byte
+	if len(rest) > b58BigEncodeBytes {
+		digits = []byte(new(big.Int).SetBytes(rest).Text(58))
+		for i, c := range digits {
+			digits[i] = b58FromBigDigit[c]
+		}
+	} else {
+		digits = base58EncodeLimbs(rest)
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = b58Alphabet[0]
+	}
+	copy(out[zeros:], digits)
+	return string(out)
+}
+
+// 分组运算编码，输入不含前导零字节，返回不含前导 '1' 的数字
+func base58EncodeLimbs(src []byte) []byte {
+	if len(src) == 0 {
+		return nil
+	}
+	// 低位在前的 58^5 进制分组
+	limbs := make([]uint32, 0, len(src)*138/500+2)
+	// 首组取 len%4 个字节，其余每组 4 个字节
+	chunk := len(src) % 4
+	if chunk == 0 {
+		chunk = 4
+	}
+	for i := 0; i < len(src); i, chunk = i+chunk, 4 {
+		var carry uint64
+		for _, b := range src[i : i+chunk] {
+			carry = carry<<8 | uint64(b)
+		}
+		shift := uint(8 * chunk)
+		for j, limb := range limbs {
+			t := uint64(limb)<<shift + carry
+			carry = t / b58Radix5
+			limbs[j] = uint32(t - carry*b58Radix5)
+		}
+		for carry > 0 {
+			limbs = append(limbs, uint32(carry%b58Radix5))
+			carry /= b58Radix5
+		}
+	}
+
+	// 最高分组不补前导零，其余分组固定输出 5 位
+	out := make([]byte, 0, len(limbs)*5)
+	var buf [5]byte
+	top := limbs[len(limbs)-1]
+	n := 0
+	for ; top > 0; top /= 58 {
+		buf[4-n] = b58Alphabet[top%58]
+		n++
+	}
+	out = append(out, buf[5-n:]...)
+	for j := len(limbs) - 2; j >= 0; j-- {
+		limb := limbs[j]
+		for k := 4; k >= 0; k-- {
+			buf[k] = b58Alphabet[limb%58]
+			limb /= 58
+		}
+		out = append(out, buf[:]...)
+	}
+	return out
+}
+
+// base58Decode 将 Base58 字符串解码为字节，出现字母表以外的字符时返回错误
+func base58Decode(s string) ([]byte, error) {
+	return base58DecodeContext(nil, s)
+}
+
+// base58DecodeContext 同 base58Decode，ctx 非nil时在大输入的分治解码中检查取消
+func base58DecodeContext(ctx context.Context, s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == b58Alphabet[0] {
+		zeros++
+	}
+	rest := s[zeros:]
+	for i := 0; i < len(rest); i++ {
+		if b58DecodeTable[rest[i]] < 0 {
+			return nil, errInvalidBase58
+		}
+	}
+
+	var value []byte
+	if len(rest) > b58BigDecodeDigits {
+		n, err := base58DecodeBig(ctx, rest, map[int]*big.Int{})
+		if err != nil {
+			return nil, err
+		}
+		value = n.Bytes()
+	} else {
+		value = base58DecodeLimbs(rest)
+	}
+	out := make([]byte, zeros+len(value))
+	copy(out[zeros:], value)
+	return out, nil
+}
+
+// 分组运算解码，输入已校验且不含前导 '1'，返回不含前导零的字节
+func base58DecodeLimbs(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	// 低位在前的 2^32 进制分组
+	limbs := make([]uint32, 0, len(s)*733/4000+2)
+	// 首组取 len%5 位，其余每组 5 位
+	chunk := len(s) % 5
+	if chunk == 0 {
+		chunk = 5
+	}
+	for i := 0; i < len(s); i, chunk = i+chunk, 5 {
+		var carry uint64
+		mul := uint64(1)
+		for k := i; k < i+chunk; k++ {
+			carry = carry*58 + uint64(b58DecodeTable[s[k]])
+			mul *= 58
+		}
+		for j, limb := range limbs {
+			t := uint64(limb)*mul + carry
+			limbs[j] = uint32(t)
+			carry = t >> 32
+		}
+		for carry > 0 {
+			limbs = append(limbs, uint32(carry))
+			carry >>= 32
+		}
+	}
+
+	// 低半部分可能全为 '1'，即数值为零
+	if len(limbs) == 0 {
+		return nil
+	}
+	out := make([]byte, 0, len(limbs)*4)
+	top := limbs[len(limbs)-1]
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(top >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	for j := len(limbs) - 2; j >= 0; j-- {
+		limb := limbs[j]
+		out = append(out, byte(limb>>24), byte(limb>>16), byte(limb>>8), byte(limb))
+	}
+	return out
+}
+
+// 分治解码：高半部分 * 58^len(低半部分) + 低半部分，pows 缓存用到的幂
+func base58DecodeBig(ctx context.Context, s string, pows map[int]*big.Int) (*big.Int, error) {
+	if len(s) <= b58BigDecodeDigits {
+		return new(big.Int).SetBytes(base58DecodeLimbs(s)), nil
+	}
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	lo := len(s) / 2
+	pow, ok := pows[lo]
+	if !ok {
+		pow = new(big.Int).Exp(big.NewInt(58), big.NewInt(int64(lo)), nil)
+		pows[lo] = pow
+	}
+	hi, err := base58DecodeBig(ctx, s[:len(s)-lo], pows)
+	if err != nil {
+		return nil, err
+	}
+	low, err := base58DecodeBig(ctx, s[len(s)-lo:], pows)
+	if err != nil {
+		return nil, err
+	}
+	hi.Mul(hi, pow)
+	return hi.Add(hi, low), nil
+}
@@ -0,0 +1,49 @@
+package internal
+
+/**
+--- 链式构造CML双序列 ---
+每次追加都是"关系符+token"成对写入，所以交替规律由构造方式本身保证，
+不需要像 New 那样在运行时逐个检查奇偶位。
+*/
+
+// Build 以首个token开启一条链式构造的CML双序列
+// 示例: Build("万有引力").Map("牛顿").Set("自然哲学的数学原理").Remark("1687 年")
+func Build(token string) *CmlFragments {
+	return &CmlFragments{
+		Tokens:    []string{token},
+		Relations: []string{},
+	}
+}
+
+// Then 以指定的关系符连接下一个token，返回自身以便继续链式调用
+// 非法的关系符不会在这里panic，而是在 IsValid 或编码时报错
+func (f *CmlFragments) Then(rel Relation, token string) *CmlFragments {
+	f.Relations = append(f.Relations, rel.String())
+	f.Tokens = append(f.Tokens, token)
+	return f
+}
+
+// Remark 以补充关系 @ 连接下一个token
+func (f *CmlFragments) Remark(token string) *CmlFragments {
+	return f.Then(RelRemark, token)
+}
+
+// Line 以线性递进关系 . 连接下一个token
+func (f *CmlFragments) Line(token string) *CmlFragments {
+	return f.Then(RelLinear, token)
+}
+
+// Set 以并列集合关系 + 连接下一个token
+func (f *CmlFragments) Set(token string) *CmlFragments {
+	return f.Then(RelSet, token)
+}
+
+// Map 以映射关系 : 连接下一个token
+func (f *CmlFragments) Map(token string) *CmlFragments {
+	return f.Then(RelMapping, token)
+}
+
+// Combine 以组合关系(空格)连接下一个token
+func (f *CmlFragments) Combine(token string) *CmlFragments {
+	return f.Then(RelCombine, token)
+}
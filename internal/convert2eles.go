@@ -4,62 +4,42 @@ package internal
 --- 二、基元和CML字符串互转 ---
 */
 import (
-	"encoding/base64"
 	"fmt"
 	"strings"
-
-	"github.com/shengdoushi/base58"
 )
 
 // CML2Elements 将CML字符串解析为基元序列
 func CML2Elements(encoded string) (*CmlElements, error) {
-	//基础的长度和首字符检查
-	if err := cmlBaseCheck(encoded); err != nil {
-		return nil, err
-	}
-
-	mode := encoded[0]
-	payload := encoded[1:]
-	var rawPayload string
-
-	// 第一层整体解码：还原语义荷载
-	switch mode {
-	case ModeA:
-		// 解码整体荷载
-		b, err := base58.Decode(payload, base58.BitcoinAlphabet)
-		if err != nil {
-			return nil, fmt.Errorf("base58整体解码失败: %v", err)
-		}
-		rawPayload = string(b)
+	return CML2ElementsWith(encoded, nil)
+}
 
-	case ModeC, ModeQ:
-		b, err := base64.RawURLEncoding.DecodeString(payload)
-		if err != nil {
-			return nil, fmt.Errorf("base64URL整体解码失败: %v", err)
-		}
-		rawPayload = string(b)
-	case ModeP:
-		//p模式是单层架构
-		rawPayload = payload
+// parseRawPayload 解析解密后的原始荷载字符串
+func parseRawPayload2Single(raw string, mode uint8, opts *DecodeOptions) (*CmlElements, error) {
+	var elements CmlElements
+	err := scanRawPayload(raw, mode, opts, func(el *CmlElement) bool {
+		elements = append(elements, el)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
-	//将实际的荷载解码成单序列
-	return parseRawPayload2Single(rawPayload, mode)
+	return &elements, nil
 }
 
-// parseRawPayload 解析解密后的原始荷载字符串
-func parseRawPayload2Single(raw string, mode uint8) (*CmlElements, error) {
+// scanRawPayload 按平铺顺序逐个解码基元并回调，yield 返回 false 时停止且不再解码剩余token
+func scanRawPayload(raw string, mode uint8, opts *DecodeOptions, yield func(*CmlElement) bool) error {
 	n := len(raw)
 	if n == 0 {
-		return nil, fmt.Errorf("非法的空CML")
+		return fmt.Errorf("非法的空CML")
 	}
 	// 定义5个分隔符集合
 	const seps = "@.+: "
 	//关系符必然不出现在首位和尾位
 	if strings.ContainsAny(string(raw[0]), seps) {
-		return nil, fmt.Errorf("语法错误: CML禁止以关系符开头:'%c'", raw[0])
+		return fmt.Errorf("语法错误: CML禁止以关系符开头:'%c'", raw[0])
 	}
 	if strings.ContainsAny(string(raw[n-1]), seps) {
-		return nil, fmt.Errorf("语法错误: CML禁止以关系符开头: '%c'", raw[n-1])
+		return fmt.Errorf("语法错误: CML禁止以关系符开头: '%c'", raw[n-1])
 	}
 
 	/**
@@ -67,8 +47,7 @@ func parseRawPayload2Single(raw string, mode uint8) (*CmlElements, error) {
 	1、对于 A 和 C 模式，Token 是全量编码过的
 	2、对于 Q 和 P 模式，Token 可能是明文或带 ! 的编码文
 	*/
-	var elements CmlElements
-	lastIdx := 0
+	lastIdx, count := 0, 0
 	for i := 0; i < len(raw); i++ {
 		/**
 		因为语法规定编码或原文token，不可以包含特殊字符，且多字节字符的每一段字节编码开头都是1
@@ -83,18 +62,23 @@ func parseRawPayload2Single(raw string, mode uint8) (*CmlElements, error) {
 
 			// --- 严格校验逻辑 ---
 			if tokenPart == "" {
-				return nil, fmt.Errorf("非法CML: 空token在字节位置处: %d", i)
+				return fmt.Errorf("非法CML: 空token在字节位置处: %d", i)
 			}
 			// 正常解码Token
-			val, err := decodeToken(tokenPart, mode)
+			val, err := opts.token(tokenPart, mode, count)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			count++
+			//产出token
+			if !yield(&CmlElement{Type: TypeToken, Value: val}) {
+				return nil
 			}
-			//将token加入基元序列
-			elements = append(elements, &CmlElement{Type: TypeToken, Value: val})
 
-			// 2. 提取分隔符，假如基元序列
-			elements = append(elements, &CmlElement{Type: TypeSeparator, Value: string(b)})
+			// 2. 提取分隔符，产出关系符
+			if !yield(&CmlElement{Type: TypeSeparator, Value: raw[i : i+1]}) {
+				return nil
+			}
 
 			// 3. 更新扫描索引
 			lastIdx = i + 1
@@ -104,11 +88,11 @@ func parseRawPayload2Single(raw string, mode uint8) (*CmlElements, error) {
 	}
 	// 剩余的一个必然是Token
 	finalToken := raw[lastIdx:]
-	val, err := decodeToken(finalToken, mode)
+	val, err := opts.token(finalToken, mode, count)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	//将token加入基元序列
-	elements = append(elements, &CmlElement{Type: TypeToken, Value: val})
-	return &elements, nil
+	//产出token
+	yield(&CmlElement{Type: TypeToken, Value: val})
+	return nil
 }
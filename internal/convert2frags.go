@@ -10,15 +10,11 @@ import (
 
 // CML2Elements 将编码后的CML字符串解析为基元序列
 func CML2Fragments(encoded string) (*CmlFragments, error) {
-	mode, rawPayload, err := decodePayload(encoded)
-	if err != nil {
-		return nil, err
-	}
-	return parseRawPayload2Double(rawPayload, mode)
+	return CML2FragmentsWith(encoded, nil)
 }
 
 // 解析解密后的原始荷载字符串，转换成两类基元的双序列
-func parseRawPayload2Double(raw string, mode uint8) (*CmlFragments, error) {
+func parseRawPayload2Double(raw string, mode uint8, opts *DecodeOptions) (*CmlFragments, error) {
 	n := len(raw)
 	if n == 0 {
 		return nil, fmt.Errorf("非法的空CML")
@@ -63,7 +59,7 @@ func parseRawPayload2Double(raw string, mode uint8) (*CmlFragments, error) {
 				return nil, fmt.Errorf("非法CML: 空token在字节位置处: %d", i)
 			}
 			// 正常解码Token
-			val, err := decodeToken(tokenPart, mode)
+			val, err := opts.token(tokenPart, mode, len(fragments.Tokens))
 			if err != nil {
 				return nil, err
 			}
@@ -85,7 +81,7 @@ func parseRawPayload2Double(raw string, mode uint8) (*CmlFragments, error) {
 	*/
 	finalToken := raw[lastIdx:]
 	//具体解码见分晓
-	val, err := decodeToken(finalToken, mode)
+	val, err := opts.token(finalToken, mode, len(fragments.Tokens))
 	if err != nil {
 		return nil, err
 	}
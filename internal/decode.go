@@ -4,15 +4,16 @@ package internal
 --- 二、基元和CML字符串互转 ---
 */
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"strings"
-
-	"github.com/shengdoushi/base58"
+	"unicode/utf8"
 )
 
 // 上层整体解码,返回编码模式和有效荷载
-func decodePayload(encoded string) (uint8, string, error) {
+// strict 为 true 时拒绝编码器不会产生的非规范荷载；ctx 非nil时 a 模式的整体解码过程中检查取消
+func decodePayload(ctx context.Context, encoded string, strict bool) (uint8, string, error) {
 	//基础的长度和首字符检查
 	if err := cmlBaseCheck(encoded); err != nil {
 		return 0, "", err
@@ -26,8 +27,11 @@ func decodePayload(encoded string) (uint8, string, error) {
 	switch mode {
 	case ModeA:
 		// 解码整体荷载
-		b, err := base58.Decode(payload, base58.BitcoinAlphabet)
+		b, err := base58DecodeContext(ctx, payload)
 		if err != nil {
+			if ctx != nil && ctx.Err() != nil {
+				return 0, "", err
+			}
 			return 0, "", fmt.Errorf("base58整体解码失败: %v", err)
 		}
 		rawPayload = string(b)
@@ -37,6 +41,9 @@ func decodePayload(encoded string) (uint8, string, error) {
 		if err != nil {
 			return 0, "", fmt.Errorf("base64URL整体解码失败: %v", err)
 		}
+		if strict && !isCanonicalBase64(payload, b) {
+			return 0, "", &StrictError{Kind: StrictNonCanonicalBase64, Token: payload}
+		}
 		rawPayload = string(b)
 	case ModeP:
 		//p模式是单层架构
@@ -47,14 +54,19 @@ func decodePayload(encoded string) (uint8, string, error) {
 }
 
 // token级解码原文
-func decodeToken(rawToken string, mode uint8) (string, error) {
+// strict 为 true 时拒绝编码器不会产生的非规范token
+func decodeToken(rawToken string, mode uint8, strict bool) (string, error) {
 	switch mode {
 	case ModeA:
 		// 直接解码原文
-		b, err := base58.Decode(rawToken, base58.BitcoinAlphabet)
+		b, err := base58Decode(rawToken)
 		if err != nil {
 			return "", fmt.Errorf("base58解码token原文失败: %v", err)
 		}
+		// Base58 与字节序列一一对应，无需检查规范性
+		if strict && !utf8.Valid(b) {
+			return "", &StrictError{Kind: StrictInvalidUTF8, Token: rawToken}
+		}
 		return string(b), nil
 	case ModeC:
 		// 语言强制要求原文编码是不能带有等号的原始格式，不需要适配=！
@@ -62,21 +74,67 @@ func decodeToken(rawToken string, mode uint8) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("base64URL解码token原文失败: %v", err)
 		}
+		if strict {
+			if err := checkStrictBase64(rawToken, b); err != nil {
+				return "", err
+			}
+		}
 		return string(b), nil
 	case ModeQ, ModeP:
 		// 检查是否有混编转义符 '!'，有则切除，进行解码
 		if before, ok := strings.CutSuffix(rawToken, "!"); ok {
+			// 编码器不会产生空token，单独的 '!' 也就不是合法的转义
+			if before == "" {
+				return "", fmt.Errorf("非法CML: 转义token为空 [%s]", rawToken)
+			}
 			// 只有不带 '!' 的原荷载部分才进行 Base64 解码
 			b, err := base64.RawURLEncoding.DecodeString(before)
 			if err != nil {
 				// 如果解码失败，说明数据在传输中可能损坏
 				return "", fmt.Errorf("base64URL解码混编token原文失败 [%s]: %w", rawToken, err)
 			}
+			if strict {
+				if err := checkStrictBase64(before, b); err != nil {
+					return "", err
+				}
+				// 编码器只转义包含保留字符的token
+				if !strings.ContainsAny(string(b), reservedChars) {
+					return "", &StrictError{Kind: StrictNeedlessEscape, Token: rawToken}
+				}
+			}
 			return string(b), nil
 		}
 
+		if strict {
+			// 明文中的 '!' 必须经过转义
+			if strings.Contains(rawToken, "!") {
+				return "", &StrictError{Kind: StrictUnescapedReserved, Token: rawToken}
+			}
+			if !utf8.ValidString(rawToken) {
+				return "", &StrictError{Kind: StrictInvalidUTF8, Token: rawToken}
+			}
+		}
 		// 是明文就直接返回
 		return rawToken, nil
 	}
 	return rawToken, nil
 }
+
+// 需要转义的保留字符集：5关系符+编码转义符！，与 processToken 一致
+const reservedChars = "@.+: !"
+
+// 规范的 base64 重新编码后与原文一致：填充位为零、不含被解码器忽略的换行
+func isCanonicalBase64(encoded string, decoded []byte) bool {
+	return base64.RawURLEncoding.EncodedLen(len(decoded)) == len(encoded) &&
+		base64.RawURLEncoding.EncodeToString(decoded) == encoded
+}
+
+func checkStrictBase64(encoded string, decoded []byte) error {
+	if !isCanonicalBase64(encoded, decoded) {
+		return &StrictError{Kind: StrictNonCanonicalBase64, Token: encoded}
+	}
+	if !utf8.Valid(decoded) {
+		return &StrictError{Kind: StrictInvalidUTF8, Token: encoded}
+	}
+	return nil
+}
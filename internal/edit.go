@@ -0,0 +1,154 @@
+package internal
+
+/**
+--- 五、双序列的原地编辑 ---
+所有编辑都以"token+关系符"成对增删，保证交替规律始终成立。
+索引语义统一为token索引：第 i 个token是 Tokens[i]，
+关系符 Relations[i] 连接 Tokens[i] 与 Tokens[i+1]。
+任何会产生非法序列的操作都返回错误，且不修改原序列。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// 编辑前对输入做统一检查
+func checkEditInput(token string, rel Relation) error {
+	if token == "" {
+		return errors.New("CML编辑失败: token不应为空串")
+	}
+	if !rel.IsValid() {
+		return fmt.Errorf("CML编辑失败: 非法的关系符 %s", rel)
+	}
+	return nil
+}
+
+// Append 在末尾追加 rel token
+func (f *CmlFragments) Append(rel Relation, token string) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	if err := checkEditInput(token, rel); err != nil {
+		return err
+	}
+	f.Relations = append(f.Relations, rel.String())
+	f.Tokens = append(f.Tokens, token)
+	return nil
+}
+
+// Prepend 在开头插入 token rel
+func (f *CmlFragments) Prepend(token string, rel Relation) error {
+	return f.InsertAt(0, token, rel)
+}
+
+// InsertAt 插入token，使其成为新的第 i 个token，0 <= i <= token数量
+// - i 小于token数量时，写入 token rel 原Tokens[i]，rel 连接新token与其右侧
+// - i 等于token数量时等价于 Append，rel 连接原末尾token与新token
+func (f *CmlFragments) InsertAt(i int, token string, rel Relation) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	if err := checkEditInput(token, rel); err != nil {
+		return err
+	}
+	n := len(f.Tokens)
+	if i < 0 || i > n {
+		return fmt.Errorf("CML编辑失败: 插入索引 %d 越界 [0, %d]", i, n)
+	}
+	if i == n {
+		return f.Append(rel, token)
+	}
+	f.Tokens = slices.Insert(f.Tokens, i, token)
+	f.Relations = slices.Insert(f.Relations, i, rel.String())
+	return nil
+}
+
+// RemoveAt 删除第 i 个token及其一侧的关系符
+// - 优先删除右侧关系符 Relations[i]
+// - 删除末尾token时删除左侧关系符 Relations[i-1]
+// 序列只剩一个token时不允许删除
+func (f *CmlFragments) RemoveAt(i int) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	n := len(f.Tokens)
+	if i < 0 || i >= n {
+		return fmt.Errorf("CML编辑失败: 删除索引 %d 越界 [0, %d)", i, n)
+	}
+	if n == 1 {
+		return errors.New("CML编辑失败: 不能删除唯一的token")
+	}
+	relIdx := i
+	if i == n-1 {
+		relIdx = i - 1
+	}
+	f.Tokens = slices.Delete(f.Tokens, i, i+1)
+	f.Relations = slices.Delete(f.Relations, relIdx, relIdx+1)
+	return nil
+}
+
+// ReplaceToken 替换第 i 个token
+func (f *CmlFragments) ReplaceToken(i int, token string) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("CML编辑失败: token不应为空串")
+	}
+	if i < 0 || i >= len(f.Tokens) {
+		return fmt.Errorf("CML编辑失败: token索引 %d 越界 [0, %d)", i, len(f.Tokens))
+	}
+	f.Tokens[i] = token
+	return nil
+}
+
+// ReplaceRelation 替换第 i 个关系符，即 Tokens[i] 与 Tokens[i+1] 之间的关系
+func (f *CmlFragments) ReplaceRelation(i int, rel Relation) error {
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	if !rel.IsValid() {
+		return fmt.Errorf("CML编辑失败: 非法的关系符 %s", rel)
+	}
+	if i < 0 || i >= len(f.Relations) {
+		return fmt.Errorf("CML编辑失败: 关系符索引 %d 越界 [0, %d)", i, len(f.Relations))
+	}
+	f.Relations[i] = rel.String()
+	return nil
+}
+
+// Slice 截取token区间 [i, j) 及其内部的关系符，返回新的序列，不共享底层数组
+// 要求 0 <= i < j <= token数量
+func (f *CmlFragments) Slice(i, j int) (*CmlFragments, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	n := len(f.Tokens)
+	if i < 0 || j > n || i >= j {
+		return nil, fmt.Errorf("CML编辑失败: 截取区间 [%d, %d) 不合法，token数量为 %d", i, j, n)
+	}
+	return &CmlFragments{
+		Tokens:    slices.Clone(f.Tokens[i:j]),
+		Relations: slices.Clone(f.Relations[i : j-1]),
+	}, nil
+}
+
+// Concat 以 rel 连接 f 的末尾token与 other 的首个token，返回新的序列
+func (f *CmlFragments) Concat(other *CmlFragments, rel Relation) (*CmlFragments, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	if err := other.IsValid(); err != nil {
+		return nil, err
+	}
+	if !rel.IsValid() {
+		return nil, fmt.Errorf("CML编辑失败: 非法的关系符 %s", rel)
+	}
+	tokens := make([]string, 0, len(f.Tokens)+len(other.Tokens))
+	tokens = append(append(tokens, f.Tokens...), other.Tokens...)
+	relations := make([]string, 0, len(f.Relations)+len(other.Relations)+1)
+	relations = append(append(append(relations, f.Relations...), rel.String()), other.Relations...)
+	return &CmlFragments{Tokens: tokens, Relations: relations}, nil
+}
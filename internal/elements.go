@@ -11,8 +11,6 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-
-	"github.com/shengdoushi/base58"
 )
 
 /**
@@ -30,6 +28,9 @@ func (elements *CmlElements) IsValid() error {
 	}
 	// CML物理优势：Token与分隔符严格交替
 	for i, el := range *elements {
+		if el == nil {
+			return fmt.Errorf("序列错误在索引 %d: 基元不应为nil", i)
+		}
 		expected := TypeToken
 		if i%2 != 0 {
 			expected = TypeSeparator
@@ -37,6 +38,10 @@ func (elements *CmlElements) IsValid() error {
 		if el.Type != expected {
 			return fmt.Errorf("序列错误在索引 %d: 不是期望的基元类型 %d", i, expected)
 		}
+		// 关系符必须是规范定义的单字节符号
+		if el.Type == TypeSeparator && (len(el.Value) != 1 || !Relation(el.Value[0]).IsValid()) {
+			return fmt.Errorf("序列错误在索引 %d: 非法的关系符 '%s'", i, el.Value)
+		}
 	}
 	return nil
 }
@@ -47,27 +52,27 @@ func (elements *CmlElements) IsValid() error {
 
 // EncodeA 编码成 a 模式，双层base58，字符集普适性最好，但性能不利于大规模场景
 func (elements *CmlElements) EncodeA() (string, error) {
-	if elements == nil || len(*elements) == 0 {
-		return "", errors.New("CML基元序列不应为nil")
+	if err := elements.validateEncode(); err != nil {
+		return "", err
 	}
 	var sb strings.Builder // 使用自动扩容的切片来避免循环分配，提升性能
 	for _, el := range *elements {
 		if el.Type == TypeToken {
 			// token级编码
-			sb.WriteString(base58.Encode([]byte(el.Value), base58.BitcoinAlphabet))
+			sb.WriteString(base58Encode([]byte(el.Value)))
 		} else {
 			sb.WriteString(el.Value)
 		}
 	}
 	// 整体编码
-	payload := base58.Encode([]byte(sb.String()), base58.BitcoinAlphabet)
+	payload := base58Encode([]byte(sb.String()))
 	return "a" + payload, nil
 }
 
 // EncodeC 编码成 c 模式（高性能 base64）
 func (elements *CmlElements) EncodeC() (string, error) {
-	if elements == nil || len(*elements) == 0 {
-		return "", errors.New("CML基元序列不应为nil")
+	if err := elements.validateEncode(); err != nil {
+		return "", err
 	}
 	var sb strings.Builder
 	for _, el := range *elements {
@@ -82,16 +87,16 @@ func (elements *CmlElements) EncodeC() (string, error) {
 
 // EncodeP 编码成 p 模式（单层明文混编，最小熵增）
 func (elements *CmlElements) EncodeP() (string, error) {
-	if elements == nil || len(*elements) == 0 {
-		return "", errors.New("CML基元序列不应为nil")
+	if err := elements.validateEncode(); err != nil {
+		return "", err
 	}
 	return "p" + elements.buildMixedPayload(), nil
 }
 
 // EncodeQ 编码成 q 模式（双层混编，在不可读的前提上，提供最小熵增）
 func (elements *CmlElements) EncodeQ() (string, error) {
-	if elements == nil || len(*elements) == 0 {
-		return "", errors.New("CML基元序列不应为nil")
+	if err := elements.validateEncode(); err != nil {
+		return "", err
 	}
 	payload := elements.buildMixedPayload()
 	return "q" + base64.RawURLEncoding.EncodeToString([]byte(payload)), nil
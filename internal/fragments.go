@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-
-	"github.com/shengdoushi/base58"
 )
 
 // New 将平铺的字符串切片转换为双列表结构的 CmlFragments
@@ -39,9 +37,8 @@ func New(arr []string) (*CmlFragments, error) {
 			fragments.Tokens = append(fragments.Tokens, val)
 		} else {
 			// 奇数索引一定是 Relation
-			const seps = "@.+: "
 			// CML 规范定义的五个合法符：@, ., +, :, 空格
-			if len(val) != 1 || !strings.ContainsAny(val, seps) {
+			if len(val) != 1 || !Relation(val[0]).IsValid() {
 				return nil, fmt.Errorf("语法错误: CML禁止以关系符开头:'%s'", val)
 			}
 			fragments.Relations = append(fragments.Relations, val)
@@ -70,9 +67,38 @@ func (f *CmlFragments) IsValid() error {
 	if tLen != rLen+1 {
 		return fmt.Errorf("CML基元双序列组成不合法: Token数量(%d)与关系符数量(%d)不匹配", tLen, rLen)
 	}
+	// 关系符必须是规范定义的单字节符号，链式构造时可能传入非法的 Relation
+	for i, rel := range f.Relations {
+		if len(rel) != 1 || !Relation(rel[0]).IsValid() {
+			return fmt.Errorf("CML基元双序列在关系符索引 %d 处不合法: '%s'", i, rel)
+		}
+	}
 	return nil
 }
 
+/**
+--- 类型化的关系符访问 ---
+Relations 字段保持 []string 以兼容已有代码，类型化的值通过访问器获取
+*/
+
+// RelationAt 返回索引 i 处的类型化关系符，索引越界会 panic，与切片访问一致
+func (f *CmlFragments) RelationAt(i int) Relation {
+	rel := f.Relations[i]
+	if len(rel) != 1 {
+		return 0
+	}
+	return Relation(rel[0])
+}
+
+// TypedRelations 返回全部关系符的类型化副本
+func (f *CmlFragments) TypedRelations() []Relation {
+	rels := make([]Relation, len(f.Relations))
+	for i := range f.Relations {
+		rels[i] = f.RelationAt(i)
+	}
+	return rels
+}
+
 /**
 ------------------------ 编码类方法 --------------------------
 */
@@ -80,7 +106,7 @@ func (f *CmlFragments) IsValid() error {
 // EncodeA 编码成 a 模式：双层 base58
 // 字符集普适性最好，但性能不理想，适用于对字符集安全性要求极高的场景
 func (f *CmlFragments) EncodeA() (string, error) {
-	if err := f.IsValid(); err != nil {
+	if err := f.validateEncode(); err != nil {
 		return "", err
 	}
 
@@ -88,7 +114,7 @@ func (f *CmlFragments) EncodeA() (string, error) {
 	// 遍历 Tokens 和 Relations 进行交替拼接
 	for i := 0; i < len(f.Tokens); i++ {
 		// Token 级 base58 编码
-		sb.WriteString(base58.Encode([]byte(f.Tokens[i]), base58.BitcoinAlphabet))
+		sb.WriteString(base58Encode([]byte(f.Tokens[i])))
 
 		// 拼接触点关系符（最后一个 Token 后没有关系符）
 		if i < len(f.Relations) {
@@ -97,14 +123,14 @@ func (f *CmlFragments) EncodeA() (string, error) {
 	}
 
 	// 整体进行二次 base58 编码
-	payload := base58.Encode([]byte(sb.String()), base58.BitcoinAlphabet)
+	payload := base58Encode([]byte(sb.String()))
 	return "a" + payload, nil
 }
 
 // EncodeC 编码成 c 模式：双层 base64 (高性能)
 // 适合大规模、高并发处理场景
 func (f *CmlFragments) EncodeC() (string, error) {
-	if err := f.IsValid(); err != nil {
+	if err := f.validateEncode(); err != nil {
 		return "", err
 	}
 
@@ -126,7 +152,7 @@ func (f *CmlFragments) EncodeC() (string, error) {
 // EncodeP 编码成 p 模式：单层明文混编
 // 保持最小熵增，提供最佳的可读性与长度比
 func (f *CmlFragments) EncodeP() (string, error) {
-	if err := f.IsValid(); err != nil {
+	if err := f.validateEncode(); err != nil {
 		return "", err
 	}
 	return "p" + f.buildBase64URLPayload(), nil
@@ -135,13 +161,29 @@ func (f *CmlFragments) EncodeP() (string, error) {
 // EncodeQ 编码成 q 模式：双层混编
 // 在不可读的前提下，通过智能判断减少不必要的 Base64 转换，提供最小熵增
 func (f *CmlFragments) EncodeQ() (string, error) {
-	if err := f.IsValid(); err != nil {
+	if err := f.validateEncode(); err != nil {
 		return "", err
 	}
 	payload := f.buildBase64URLPayload()
 	return "q" + base64.RawURLEncoding.EncodeToString([]byte(payload)), nil
 }
 
+// Encode 按模式标识编码，mode 取 ModeA、ModeC、ModeP、ModeQ 之一
+// 适用于编码模式由配置或参数决定的场景
+func (f *CmlFragments) Encode(mode uint8) (string, error) {
+	switch mode {
+	case ModeA:
+		return f.EncodeA()
+	case ModeC:
+		return f.EncodeC()
+	case ModeP:
+		return f.EncodeP()
+	case ModeQ:
+		return f.EncodeQ()
+	}
+	return "", fmt.Errorf("不支持的CML编码模式: %c", mode)
+}
+
 /**
 --- 辅助逻辑：内部 Payload 构建 ---
 */
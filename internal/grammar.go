@@ -1,5 +1,7 @@
 package internal
 
+import "fmt"
+
 /**
 ------------------语法规范-----------------------
 */
@@ -58,3 +60,64 @@ type CmlFragments struct {
 	Tokens    []string // 所有的实体内容
 	Relations []string // 所有的关系符 (@, ., +, :, 空格)
 }
+
+/**
+------------------类型化的关系符-----------------------
+关系符在荷载中永远是单字节，用byte作为底层类型，比较和转换都是零开销
+*/
+
+// Relation 类型化的关系符，取值为上面定义的5个关系分隔符之一
+type Relation byte
+
+const (
+	RelRemark  Relation = '@' // 补充关系，对应 SepRemarkAt
+	RelLinear  Relation = '.' // 线性递进，对应 SepLineDot
+	RelSet     Relation = '+' // 并列集合，对应 SepSetXX
+	RelMapping Relation = ':' // 映射关系，对应 SepMapping
+	RelCombine Relation = ' ' // 组合关系，对应 SepCombineSpace
+)
+
+// 关系符的语义名称，与符号一一对应
+var relationNames = map[Relation]string{
+	RelRemark:  "remark",
+	RelLinear:  "linear",
+	RelSet:     "set",
+	RelMapping: "mapping",
+	RelCombine: "combine",
+}
+
+// ParseRelation 将荷载中的单字节解析为类型化关系符
+func ParseRelation(b byte) (Relation, error) {
+	r := Relation(b)
+	if !r.IsValid() {
+		return 0, fmt.Errorf("非法的CML关系符: %q", b)
+	}
+	return r, nil
+}
+
+// IsValid 判断是否为CML规范定义的5个关系符之一
+func (r Relation) IsValid() bool {
+	switch r {
+	case RelRemark, RelLinear, RelSet, RelMapping, RelCombine:
+		return true
+	}
+	return false
+}
+
+// Symbol 返回关系符在荷载中的原始字节
+func (r Relation) Symbol() byte {
+	return byte(r)
+}
+
+// String 返回关系符的单字符串形式，即荷载中的写法
+func (r Relation) String() string {
+	if !r.IsValid() {
+		return fmt.Sprintf("Relation(0x%02x)", byte(r))
+	}
+	return string(rune(r))
+}
+
+// Name 返回关系符的语义名称，例如 "remark"、"mapping"，非法关系符返回空串
+func (r Relation) Name() string {
+	return relationNames[r]
+}
@@ -0,0 +1,166 @@
+package internal
+
+/**
+--- 七、token驻留与编号表示 ---
+大规模解码时，实体名、年份等token会被反复分配。
+驻留器为每个不同的token只保留一份存储，并分配稳定的 uint32 编号，
+编号表示可以直接用于统计分析，不必再比较字符串。
+*/
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Interner 并发安全的token驻留器，同时是 token <-> 编号 的双向表
+type Interner struct {
+	mu    sync.RWMutex
+	ids   map[string]uint32
+	strs  []string
+	hits  atomic.Uint64
+	miss  atomic.Uint64
+	saved atomic.Uint64
+}
+
+// InternStats 驻留统计
+type InternStats struct {
+	Hits       uint64 // 命中已驻留token的次数
+	Misses     uint64 // 新增token的次数
+	BytesSaved uint64 // 命中时避免重复持有的字节数
+	Unique     int    // 不同token的数量
+}
+
+// HitRate 命中率，没有任何调用时为 0
+func (s InternStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// NewInterner 创建空的驻留器
+func NewInterner() *Interner {
+	return &Interner{ids: make(map[string]uint32)}
+}
+
+// Intern 返回与 s 内容相同的驻留字符串，实现 TokenInterner
+func (in *Interner) Intern(s string) string {
+	_, str := in.intern(s)
+	return str
+}
+
+// ID 驻留 s 并返回其编号，编号从 0 开始按首次出现顺序分配
+func (in *Interner) ID(s string) uint32 {
+	id, _ := in.intern(s)
+	return id
+}
+
+func (in *Interner) intern(s string) (uint32, string) {
+	in.mu.RLock()
+	id, ok := in.ids[s]
+	var str string
+	if ok {
+		str = in.strs[id]
+	}
+	in.mu.RUnlock()
+	if ok {
+		in.hit(s)
+		return id, str
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if id, ok := in.ids[s]; ok {
+		in.hit(s)
+		return id, in.strs[id]
+	}
+	// 克隆一份，避免子串把整段荷载一直留在内存中
+	owned := strings.Clone(s)
+	id = uint32(len(in.strs))
+	in.strs = append(in.strs, owned)
+	in.ids[owned] = id
+	in.miss.Add(1)
+	return id, owned
+}
+
+func (in *Interner) hit(s string) {
+	in.hits.Add(1)
+	in.saved.Add(uint64(len(s)))
+}
+
+// Lookup 按编号取回token
+func (in *Interner) Lookup(id uint32) (string, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if int(id) >= len(in.strs) {
+		return "", false
+	}
+	return in.strs[id], true
+}
+
+// Len 不同token的数量
+func (in *Interner) Len() int {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return len(in.strs)
+}
+
+// Stats 当前的驻留统计
+func (in *Interner) Stats() InternStats {
+	return InternStats{
+		Hits:       in.hits.Load(),
+		Misses:     in.miss.Load(),
+		BytesSaved: in.saved.Load(),
+		Unique:     in.Len(),
+	}
+}
+
+/**
+------------------------ 编号表示 --------------------------
+*/
+
+// IDFragments 以token编号表示的双序列，编号属于生成它的驻留器
+type IDFragments struct {
+	TokenIDs  []uint32
+	Relations []Relation
+}
+
+// ToIDs 将双序列转换为编号表示，新出现的token会被驻留
+func (in *Interner) ToIDs(f *CmlFragments) (*IDFragments, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	idf := &IDFragments{
+		TokenIDs:  make([]uint32, len(f.Tokens)),
+		Relations: f.TypedRelations(),
+	}
+	for i, token := range f.Tokens {
+		idf.TokenIDs[i] = in.ID(token)
+	}
+	return idf, nil
+}
+
+// FromIDs 将编号表示还原为双序列
+func (in *Interner) FromIDs(idf *IDFragments) (*CmlFragments, error) {
+	if idf == nil || len(idf.TokenIDs) != len(idf.Relations)+1 {
+		return nil, fmt.Errorf("编号表示不合法: token与关系符数量不匹配")
+	}
+	f := &CmlFragments{
+		Tokens:    make([]string, len(idf.TokenIDs)),
+		Relations: make([]string, len(idf.Relations)),
+	}
+	for i, id := range idf.TokenIDs {
+		token, ok := in.Lookup(id)
+		if !ok {
+			return nil, fmt.Errorf("编号表示不合法: 未知的token编号 %d", id)
+		}
+		f.Tokens[i] = token
+	}
+	for i, rel := range idf.Relations {
+		f.Relations[i] = rel.String()
+	}
+	return f, f.IsValid()
+}
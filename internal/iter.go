@@ -0,0 +1,197 @@
+package internal
+
+/**
+--- 三、基于 Go 1.23 迭代器的遍历 ---
+消费方不再需要自己做 Tokens[i]/Relations[i] 的下标运算，
+双序列和单序列提供同名的迭代方法，遍历语义完全一致。
+*/
+
+import (
+	"iter"
+)
+
+// Triple 相邻两个token及连接它们的关系符，即 Left Rel Right
+type Triple struct {
+	Left  string
+	Rel   Relation
+	Right string
+}
+
+/**
+------------------------ 双序列迭代 --------------------------
+*/
+
+// All 按 T R T R ... T 的平铺顺序产出 (平铺索引, 基元)
+func (f *CmlFragments) All() iter.Seq2[int, CmlElement] {
+	return func(yield func(int, CmlElement) bool) {
+		if f == nil {
+			return
+		}
+		for i, token := range f.Tokens {
+			if !yield(2*i, CmlElement{Type: TypeToken, Value: token}) {
+				return
+			}
+			if i < len(f.Relations) {
+				if !yield(2*i+1, CmlElement{Type: TypeSeparator, Value: f.Relations[i]}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// TokenSeq 依次产出每一个token
+// 由于 Tokens 已是导出字段名，迭代方法命名为 TokenSeq
+func (f *CmlFragments) TokenSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if f == nil {
+			return
+		}
+		for _, token := range f.Tokens {
+			if !yield(token) {
+				return
+			}
+		}
+	}
+}
+
+// Triples 依次产出每一组 左token-关系符-右token
+func (f *CmlFragments) Triples() iter.Seq[Triple] {
+	return func(yield func(Triple) bool) {
+		if f == nil {
+			return
+		}
+		for i := range f.Relations {
+			if i+1 >= len(f.Tokens) {
+				return
+			}
+			if !yield(Triple{Left: f.Tokens[i], Rel: f.RelationAt(i), Right: f.Tokens[i+1]}) {
+				return
+			}
+		}
+	}
+}
+
+// Pairs 从第二个token开始，依次产出 (前导关系符, token)
+// 首个token加上全部 Pairs 即可完整还原序列
+func (f *CmlFragments) Pairs() iter.Seq2[Relation, string] {
+	return func(yield func(Relation, string) bool) {
+		if f == nil {
+			return
+		}
+		for i := range f.Relations {
+			if i+1 >= len(f.Tokens) {
+				return
+			}
+			if !yield(f.RelationAt(i), f.Tokens[i+1]) {
+				return
+			}
+		}
+	}
+}
+
+/**
+------------------------ 单序列迭代 --------------------------
+*/
+
+// All 按平铺顺序产出 (索引, 基元)
+func (elements *CmlElements) All() iter.Seq2[int, CmlElement] {
+	return func(yield func(int, CmlElement) bool) {
+		if elements == nil {
+			return
+		}
+		for i, el := range *elements {
+			if !yield(i, *el) {
+				return
+			}
+		}
+	}
+}
+
+// TokenSeq 依次产出每一个token
+func (elements *CmlElements) TokenSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if elements == nil {
+			return
+		}
+		for _, el := range *elements {
+			if el.Type != TypeToken {
+				continue
+			}
+			if !yield(el.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Triples 依次产出每一组 左token-关系符-右token，要求序列满足交替规律
+func (elements *CmlElements) Triples() iter.Seq[Triple] {
+	return func(yield func(Triple) bool) {
+		if elements == nil {
+			return
+		}
+		els := *elements
+		for i := 1; i+1 < len(els); i += 2 {
+			if !yield(Triple{Left: els[i-1].Value, Rel: separatorOf(els[i]), Right: els[i+1].Value}) {
+				return
+			}
+		}
+	}
+}
+
+// Pairs 从第二个token开始，依次产出 (前导关系符, token)
+func (elements *CmlElements) Pairs() iter.Seq2[Relation, string] {
+	return func(yield func(Relation, string) bool) {
+		if elements == nil {
+			return
+		}
+		els := *elements
+		for i := 1; i+1 < len(els); i += 2 {
+			if !yield(separatorOf(els[i]), els[i+1].Value) {
+				return
+			}
+		}
+	}
+}
+
+// 单序列中关系符基元的类型化值，非单字节的值返回0
+func separatorOf(el *CmlElement) Relation {
+	if len(el.Value) != 1 {
+		return 0
+	}
+	return Relation(el.Value[0])
+}
+
+/**
+------------------------ 流式解码 --------------------------
+*/
+
+// ElementSeq 流式解码CML字符串，按平铺顺序逐个产出基元
+// 1、整体层(第一层)解码无法分段，仍一次完成；p模式整体层零开销
+// 2、token级(第二层)解码按需进行，消费方 break 后剩余token不再解码
+// 3、遇到错误时产出一次 (零值, err) 后结束
+func ElementSeq(encoded string) iter.Seq2[CmlElement, error] {
+	return ElementSeqWith(encoded, nil)
+}
+
+// ElementSeqWith 按选项流式解码，限制与取消在逐个token解码时生效
+func ElementSeqWith(encoded string, opts *DecodeOptions) iter.Seq2[CmlElement, error] {
+	return func(yield func(CmlElement, error) bool) {
+		if err := opts.checkInput(encoded); err != nil {
+			yield(CmlElement{}, err)
+			return
+		}
+		mode, raw, err := decodePayload(opts.context(), encoded, opts.strict())
+		if err != nil {
+			yield(CmlElement{}, err)
+			return
+		}
+		err = scanRawPayload(raw, mode, opts, func(el *CmlElement) bool {
+			return yield(*el, nil)
+		})
+		if err != nil {
+			yield(CmlElement{}, err)
+		}
+	}
+}
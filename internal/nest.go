@@ -0,0 +1,186 @@
+package internal
+
+/**
+--- 六、嵌套CML ---
+嵌套token的值本身是一段完整的CML编码，用于表达 (A + B) : C 这样的分组。
+沿用转义符 ! 的约定：嵌套token的原文为 "!" + 子CML编码 + "!"
+1、token原文始终被各模式无损编解码，所以嵌套在 a/c/q/p 四种模式下都成立
+2、原文含有 !，在 p/q 模式下会按既有规则被强制编码，不会与关系符冲突
+3、子CML统一写成 p 模式，熵增最小；解码时接受任意模式
+4、形似嵌套的普通token(如 "!pA!")和以 "!!" 开头的token在原文中再加一个 ! 前缀，
+   原文以 "!!" 开头的token总是普通token，按树解码时去掉一个 !，保证树与原文一一对应
+只有显式按树解码时才会展开嵌套和去掉转义，普通解码得到的仍是原文token
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxNestDepth 缺省的最大嵌套深度，防止恶意构造的深层嵌套耗尽资源
+const DefaultMaxNestDepth = 8
+
+// CmlTree 展开嵌套后的树形结构，Nodes 与 Relations 满足交替规律
+type CmlTree struct {
+	Nodes     []*TreeNode
+	Relations []string
+}
+
+// TreeNode 树的节点，Sub 非nil时表示这是一个嵌套token
+type TreeNode struct {
+	Token string   // 普通token为去掉转义后的值，嵌套token保留带标记的原文
+	Sub   *CmlTree // 嵌套展开后的子树
+}
+
+// Nest 将子序列包装成一个嵌套token的原文，可以作为普通token参与构造
+func Nest(sub *CmlFragments) (string, error) {
+	p, err := sub.EncodeP()
+	if err != nil {
+		return "", err
+	}
+	return EncodedExclaim + p + EncodedExclaim, nil
+}
+
+// Unnest 解析嵌套token，得到子序列
+func Unnest(token string) (*CmlFragments, error) {
+	inner, ok := nestedPayload(token)
+	if !ok {
+		return nil, errors.New("不是嵌套token: 缺少首尾的 ! 标记")
+	}
+	return CML2Fragments(inner)
+}
+
+// EscapeLiteral 转义形似嵌套的普通token，其它token原样返回
+// 直接构造双序列时，值可能以 ! 开头的普通token需要先转义，才能在按树解码时保持原值
+func EscapeLiteral(token string) string {
+	if strings.HasPrefix(token, literalPrefix) || IsNested(token) {
+		return EncodedExclaim + token
+	}
+	return token
+}
+
+// UnescapeLiteral 还原 EscapeLiteral 转义过的原文，嵌套token与其它token原样返回
+func UnescapeLiteral(token string) string {
+	if strings.HasPrefix(token, literalPrefix) {
+		return token[1:]
+	}
+	return token
+}
+
+// 转义后的普通token的前缀，嵌套token的子CML以模式字母开头，不会与之冲突
+const literalPrefix = EncodedExclaim + EncodedExclaim
+
+// IsNested 判断token是否为合法的嵌套token
+func IsNested(token string) bool {
+	inner, ok := nestedPayload(token)
+	return ok && IsCML(inner) == nil
+}
+
+// 切除首尾标记，只做语法层面的检查
+func nestedPayload(token string) (string, bool) {
+	if len(token) < 4 || !strings.HasPrefix(token, EncodedExclaim) || !strings.HasSuffix(token, EncodedExclaim) {
+		return "", false
+	}
+	inner := token[1 : len(token)-1]
+	if cmlBaseCheck(inner) != nil {
+		return "", false
+	}
+	return inner, true
+}
+
+// CML2Tree 将CML字符串解码为展开嵌套后的树，maxDepth <= 0 时使用缺省深度
+func CML2Tree(encoded string, maxDepth int) (*CmlTree, error) {
+	return CML2TreeWith(encoded, maxDepth, nil)
+}
+
+// CML2TreeWith 按选项解码并展开嵌套，每一层子CML都按同一选项解码
+func CML2TreeWith(encoded string, maxDepth int, opts *DecodeOptions) (*CmlTree, error) {
+	f, err := CML2FragmentsWith(encoded, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.TreeWith(maxDepth, opts)
+}
+
+// Tree 递归展开双序列中的嵌套token，maxDepth <= 0 时使用缺省深度
+func (f *CmlFragments) Tree(maxDepth int) (*CmlTree, error) {
+	return f.TreeWith(maxDepth, nil)
+}
+
+// TreeWith 按选项解码嵌套的子CML，超出限制或被取消时返回错误
+func (f *CmlFragments) TreeWith(maxDepth int, opts *DecodeOptions) (*CmlTree, error) {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxNestDepth
+	}
+	return f.tree(0, maxDepth, opts)
+}
+
+func (f *CmlFragments) tree(depth, maxDepth int, opts *DecodeOptions) (*CmlTree, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	t := &CmlTree{
+		Nodes:     make([]*TreeNode, len(f.Tokens)),
+		Relations: append([]string(nil), f.Relations...),
+	}
+	for i, token := range f.Tokens {
+		node := &TreeNode{Token: UnescapeLiteral(token)}
+		t.Nodes[i] = node
+		inner, ok := nestedPayload(token)
+		if !ok {
+			continue
+		}
+		sub, err := CML2FragmentsWith(inner, opts)
+		if err != nil {
+			if isFatal(err) {
+				return nil, err
+			}
+			// 形似嵌套但无法解码的token按普通token处理
+			continue
+		}
+		if depth+1 > maxDepth {
+			return nil, fmt.Errorf("CML嵌套深度超过上限 %d", maxDepth)
+		}
+		if node.Sub, err = sub.tree(depth+1, maxDepth, opts); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Fragments 将树重新收拢为双序列，子树会重新包装为嵌套token
+func (t *CmlTree) Fragments() (*CmlFragments, error) {
+	if t == nil || len(t.Nodes) == 0 {
+		return nil, errors.New("CML树不应为空")
+	}
+	f := &CmlFragments{
+		Tokens:    make([]string, len(t.Nodes)),
+		Relations: append([]string(nil), t.Relations...),
+	}
+	for i, node := range t.Nodes {
+		if node.Sub == nil {
+			f.Tokens[i] = EscapeLiteral(node.Token)
+			continue
+		}
+		sub, err := node.Sub.Fragments()
+		if err != nil {
+			return nil, err
+		}
+		if f.Tokens[i], err = Nest(sub); err != nil {
+			return nil, err
+		}
+	}
+	return f, f.IsValid()
+}
+
+// Depth 树的实际嵌套深度，没有嵌套时为 0
+func (t *CmlTree) Depth() int {
+	d := 0
+	for _, node := range t.Nodes {
+		if node.Sub != nil {
+			d = max(d, node.Sub.Depth()+1)
+		}
+	}
+	return d
+}
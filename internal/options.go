@@ -0,0 +1,198 @@
+package internal
+
+/**
+--- 解码选项 ---
+缺省解码(CML2Fragments、CML2Elements)等价于传入 nil 选项
+*/
+
+import (
+	"context"
+	"fmt"
+)
+
+// TokenInterner token驻留器，返回与输入内容相同、但可能共享存储的字符串
+type TokenInterner interface {
+	Intern(s string) string
+}
+
+// DecodeOptions 解码选项，nil 表示全部使用缺省行为
+// 各项限制为 0 时表示不限制，超出限制时返回 *LimitError
+type DecodeOptions struct {
+	// Interner 非nil时，解码出的每个token都经其驻留，重复token共享同一份存储
+	Interner TokenInterner
+	// Context 非nil时，解码前及解码过程中定期检查，取消后返回 Context.Err()
+	Context context.Context
+	// MaxInputBytes 编码字符串的最大字节数，在整体层解码前检查，避免 Base58 的平方复杂度被放大
+	MaxInputBytes int
+	// MaxTokens 最多允许的token个数
+	MaxTokens int
+	// MaxTokenBytes 单个token解码后的最大字节数；token编码长度明显超出时不再解码，直接报错
+	MaxTokenBytes int
+	// Strict 为 true 时只接受编码器会产生的规范编码，拒绝时返回 *StrictError
+	// 同一内容只有唯一的合法编码，对编码字符串的签名和内容寻址因此可信
+	Strict bool
+}
+
+// 处理不可信输入(编辑器文档、网页属性、评审机器人等)时的缺省限制
+const (
+	UntrustedMaxInputBytes = 64 << 10
+	UntrustedMaxTokens     = 4096
+	UntrustedMaxTokenBytes = 4 << 10
+)
+
+// UntrustedDecodeOptions 返回适用于不可信输入的解码选项，每次调用返回新的实例，可按需修改
+func UntrustedDecodeOptions() *DecodeOptions {
+	return &DecodeOptions{
+		MaxInputBytes: UntrustedMaxInputBytes,
+		MaxTokens:     UntrustedMaxTokens,
+		MaxTokenBytes: UntrustedMaxTokenBytes,
+	}
+}
+
+// LimitError 解码超出 DecodeOptions 中的限制
+type LimitError struct {
+	Limit  string // 超出的限制项: MaxInputBytes、MaxTokens 或 MaxTokenBytes
+	Max    int    // 限制值
+	Actual int    // 实际值；为避免继续解码，可能只是下界
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("CML超出解码限制 %s: 上限 %d, 实际至少 %d", e.Limit, e.Max, e.Actual)
+}
+
+// StrictKind 严格模式拒绝的非规范编码类别
+type StrictKind int
+
+const (
+	StrictNeedlessEscape     StrictKind = iota + 1 // 不含保留字符的token被 ! 转义
+	StrictNonCanonicalBase64                       // base64 填充位非零或含有被忽略的字符
+	StrictInvalidUTF8                              // token解码后不是合法的 UTF-8
+	StrictUnescapedReserved                        // 明文token包含未转义的保留字符 !
+)
+
+var strictKindNames = map[StrictKind]string{
+	StrictNeedlessEscape:     "不必要的转义",
+	StrictNonCanonicalBase64: "非规范的base64",
+	StrictInvalidUTF8:        "非法的UTF-8",
+	StrictUnescapedReserved:  "未转义的保留字符",
+}
+
+func (k StrictKind) String() string {
+	if name, ok := strictKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("StrictKind(%d)", int(k))
+}
+
+// StrictError 严格模式下遇到非规范编码
+type StrictError struct {
+	Kind  StrictKind
+	Token string // 出错的原始编码片段
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("非规范CML(%s): %q", e.Kind, e.Token)
+}
+
+// 每解码多少个token检查一次 Context
+const ctxCheckInterval = 64
+
+// 整体层解码前的检查
+func (o *DecodeOptions) checkInput(encoded string) error {
+	if o == nil {
+		return nil
+	}
+	if o.Context != nil {
+		if err := o.Context.Err(); err != nil {
+			return err
+		}
+	}
+	if o.MaxInputBytes > 0 && len(encoded) > o.MaxInputBytes {
+		return &LimitError{Limit: "MaxInputBytes", Max: o.MaxInputBytes, Actual: len(encoded)}
+	}
+	return nil
+}
+
+// token解码：检查限制后解码原文并驻留，n 为此前已解码的token个数
+func (o *DecodeOptions) token(rawToken string, mode uint8, n int) (string, error) {
+	if err := o.admit(rawToken, n); err != nil {
+		return "", err
+	}
+	val, err := decodeToken(rawToken, mode, o.strict())
+	if err != nil {
+		return "", err
+	}
+	return o.accept(val)
+}
+
+// token解码前的检查：数量上限、取消和编码长度
+func (o *DecodeOptions) admit(rawToken string, n int) error {
+	if o == nil {
+		return nil
+	}
+	if o.MaxTokens > 0 && n >= o.MaxTokens {
+		return &LimitError{Limit: "MaxTokens", Max: o.MaxTokens, Actual: n + 1}
+	}
+	if o.Context != nil && n%ctxCheckInterval == 0 {
+		if err := o.Context.Err(); err != nil {
+			return err
+		}
+	}
+	// Base58 与 Base64 的膨胀率都小于 2，编码长度超过两倍上限时解码结果必然超限
+	if o.MaxTokenBytes > 0 && len(rawToken) > 2*o.MaxTokenBytes+2 {
+		return &LimitError{Limit: "MaxTokenBytes", Max: o.MaxTokenBytes, Actual: len(rawToken) / 2}
+	}
+	return nil
+}
+
+// token解码后的检查与驻留
+func (o *DecodeOptions) accept(val string) (string, error) {
+	if o != nil && o.MaxTokenBytes > 0 && len(val) > o.MaxTokenBytes {
+		return "", &LimitError{Limit: "MaxTokenBytes", Max: o.MaxTokenBytes, Actual: len(val)}
+	}
+	return o.intern(val), nil
+}
+
+// 整体层解码使用的 Context，可能为 nil
+func (o *DecodeOptions) context() context.Context {
+	if o == nil {
+		return nil
+	}
+	return o.Context
+}
+
+func (o *DecodeOptions) strict() bool {
+	return o != nil && o.Strict
+}
+
+// 对解码出的token应用驻留
+func (o *DecodeOptions) intern(token string) string {
+	if o == nil || o.Interner == nil {
+		return token
+	}
+	return o.Interner.Intern(token)
+}
+
+// CML2FragmentsWith 按选项将CML字符串解析为双序列
+func CML2FragmentsWith(encoded string, opts *DecodeOptions) (*CmlFragments, error) {
+	if err := opts.checkInput(encoded); err != nil {
+		return nil, err
+	}
+	mode, rawPayload, err := decodePayload(opts.context(), encoded, opts.strict())
+	if err != nil {
+		return nil, err
+	}
+	return parseRawPayload2Double(rawPayload, mode, opts)
+}
+
+// CML2ElementsWith 按选项将CML字符串解析为单序列
+func CML2ElementsWith(encoded string, opts *DecodeOptions) (*CmlElements, error) {
+	if err := opts.checkInput(encoded); err != nil {
+		return nil, err
+	}
+	mode, rawPayload, err := decodePayload(opts.context(), encoded, opts.strict())
+	if err != nil {
+		return nil, err
+	}
+	return parseRawPayload2Single(rawPayload, mode, opts)
+}
@@ -0,0 +1,209 @@
+package internal
+
+/**
+--- 宽松修复解码 ---
+面向大模型生成或手工编辑的CML：能修复的尽量修复，并逐条报告所做的修复
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// RepairKind 修复类别
+type RepairKind int
+
+const (
+	RepairMissingMode  RepairKind = iota + 1 // 缺少模式前缀，按 p 模式解析
+	RepairEmptyToken                         // 连续关系符之间的空token被删除，关系符合并为一个
+	RepairEdgeRelation                       // 首尾多余的关系符被删除
+	RepairBadEscape                          // ! 转义token无法解码为合法文本，按明文保留
+	RepairModeFallback                       // 整体层可以解码但token无法按声明的模式解码，去掉前缀后按 p 模式解析
+)
+
+var repairKindNames = map[RepairKind]string{
+	RepairMissingMode:  "缺少模式前缀",
+	RepairEmptyToken:   "空token",
+	RepairEdgeRelation: "首尾关系符",
+	RepairBadEscape:    "非法转义",
+	RepairModeFallback: "模式回退",
+}
+
+func (k RepairKind) String() string {
+	if name, ok := repairKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("RepairKind(%d)", int(k))
+}
+
+// RepairFix 一条已应用的修复
+type RepairFix struct {
+	Kind    RepairKind
+	Offset  int // 在整体层解码后的荷载中的字节位置，RepairMissingMode 为 0
+	Message string
+}
+
+func (f RepairFix) String() string {
+	return fmt.Sprintf("%s@%d: %s", f.Kind, f.Offset, f.Message)
+}
+
+// Repair 宽松解码CML字符串，返回尽力修复后的双序列和全部修复记录
+// 1、模式前缀缺失或整体层无法按声明的模式解码时，整体按 p 模式明文重新解析；
+//
+//	整体层可以解码时前缀是可信的，只对去掉前缀的荷载按 p 模式重新解析
+//
+// 2、连续关系符之间的空token(包括空的 ! 转义token)被删除，优先保留其中第一个非空格的关系符
+// 3、首尾的关系符被删除
+// 4、p/q 模式中无法解码为合法 UTF-8 的 ! 转义token按明文保留
+// 没有任何可用token时返回错误
+func Repair(encoded string) (*CmlFragments, []RepairFix, error) {
+	return RepairWith(encoded, nil)
+}
+
+// RepairWith 按选项宽松解码，限制、取消与驻留同 CML2FragmentsWith
+// 修复本身就是接受非规范输入，Strict 被忽略；超出限制或被取消时直接返回错误，不做回退
+func RepairWith(encoded string, opts *DecodeOptions) (*CmlFragments, []RepairFix, error) {
+	if encoded == "" {
+		return nil, nil, fmt.Errorf("非法的空CML")
+	}
+	if err := opts.checkInput(encoded); err != nil {
+		return nil, nil, err
+	}
+	if strings.IndexByte("acqp", encoded[0]) >= 0 {
+		mode, raw, err := decodePayload(opts.context(), encoded, false)
+		if err != nil && isFatal(err) {
+			return nil, nil, err
+		}
+		if err == nil {
+			f, fixes, err := repairPayload(raw, mode, opts)
+			if err == nil || mode == ModeP || isFatal(err) {
+				return f, fixes, err
+			}
+			if f, fixes, err = repairPayload(encoded[1:], ModeP, opts); err != nil {
+				return nil, nil, err
+			}
+			fix := RepairFix{Kind: RepairModeFallback, Message: fmt.Sprintf("token无法按 %c 模式解码，荷载按 p 模式解析", mode)}
+			return f, append([]RepairFix{fix}, fixes...), nil
+		}
+	}
+	f, fixes, err := repairPayload(encoded, ModeP, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	fix := RepairFix{Kind: RepairMissingMode, Message: "缺少模式前缀或无法按声明的模式解码，按 p 模式解析"}
+	return f, append([]RepairFix{fix}, fixes...), nil
+}
+
+// 超出限制或被取消，不能通过改换解析方式修复
+func isFatal(err error) bool {
+	var le *LimitError
+	return errors.As(err, &le) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// 宽松解析整体层解码后的荷载；a/c 模式的token无法解码时返回错误
+func repairPayload(raw string, mode uint8, opts *DecodeOptions) (*CmlFragments, []RepairFix, error) {
+	var fixes []RepairFix
+	f := &CmlFragments{Tokens: []string{}, Relations: []string{}}
+	pending := byte(0) // 上一个token之后尚未使用的关系符
+	pendingOff := 0    // pending 所在的位置
+	runStart := -1     // 当前连续关系符的起始位置
+	lastIdx := 0
+
+	flush := func(end int) error {
+		if lastIdx == end {
+			return nil
+		}
+		tok := raw[lastIdx:end]
+		if tok == "!" && (mode == ModeQ || mode == ModeP) {
+			// 空的转义token与连续关系符之间的空token相同，删除后两侧的关系符合并
+			fixes = append(fixes, RepairFix{Kind: RepairEmptyToken, Offset: lastIdx, Message: "删除空的转义token \"!\""})
+			return nil
+		}
+		if err := opts.admit(tok, len(f.Tokens)); err != nil {
+			return err
+		}
+		val, err := decodeToken(tok, mode, false)
+		if mode == ModeQ || mode == ModeP {
+			if before, ok := strings.CutSuffix(tok, "!"); ok && (err != nil || !utf8.ValidString(val)) {
+				val = tok
+				fixes = append(fixes, RepairFix{Kind: RepairBadEscape, Offset: lastIdx,
+					Message: fmt.Sprintf("%q 不是合法的base64转义，按明文保留", before+"!")})
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if val, err = opts.accept(val); err != nil {
+			return err
+		}
+		if len(f.Tokens) > 0 {
+			f.Relations = append(f.Relations, string(pending))
+		}
+		f.Tokens = append(f.Tokens, val)
+		pending = 0
+		return nil
+	}
+
+	// 连续关系符结束时决定保留哪一个
+	endRun := func(end int) {
+		if runStart < 0 {
+			return
+		}
+		run := raw[runStart:end]
+		if len(f.Tokens) == 0 {
+			fixes = append(fixes, RepairFix{Kind: RepairEdgeRelation, Offset: runStart,
+				Message: fmt.Sprintf("删除开头的关系符 %q", run)})
+			pending = 0
+		} else {
+			chosen := run[0]
+			if i := strings.IndexFunc(run, func(r rune) bool { return r != ' ' }); i >= 0 {
+				chosen = run[i]
+			}
+			// 删除空转义token后，之前保留的非空格关系符优先
+			if pending == 0 || pending == ' ' {
+				pending, pendingOff = chosen, runStart
+			}
+			if len(run) > 1 {
+				fixes = append(fixes, RepairFix{Kind: RepairEmptyToken, Offset: runStart,
+					Message: fmt.Sprintf("连续关系符 %q 合并为 %q", run, string(pending))})
+			}
+		}
+		runStart = -1
+	}
+
+	for i := 0; i < len(raw); i++ {
+		if !Relation(raw[i]).IsValid() {
+			endRun(i)
+			continue
+		}
+		if err := flush(i); err != nil {
+			return nil, nil, err
+		}
+		if runStart < 0 {
+			runStart = i
+		}
+		lastIdx = i + 1
+	}
+	if runStart >= 0 && lastIdx == len(raw) {
+		// 以关系符结尾
+		msg := fmt.Sprintf("删除末尾的关系符 %q", raw[runStart:])
+		if len(f.Tokens) == 0 {
+			msg = fmt.Sprintf("删除开头的关系符 %q", raw[runStart:])
+		}
+		fixes = append(fixes, RepairFix{Kind: RepairEdgeRelation, Offset: runStart, Message: msg})
+	} else if err := flush(len(raw)); err != nil {
+		return nil, nil, err
+	} else if pending != 0 && len(f.Tokens) > 0 {
+		// 末尾的空转义token被删除后，它之前的关系符成为末尾关系符
+		fixes = append(fixes, RepairFix{Kind: RepairEdgeRelation, Offset: pendingOff,
+			Message: fmt.Sprintf("删除末尾的关系符 %q", string(pending))})
+	}
+	if len(f.Tokens) == 0 {
+		return nil, nil, fmt.Errorf("无法修复: CML中没有可用的token")
+	}
+	return f, fixes, nil
+}
@@ -0,0 +1,97 @@
+package internal
+
+/**
+--- 四、两种中间结构的统一抽象与互转 ---
+单序列和双序列表达的是同一份内容，互转必须无损，
+同时提供共同接口，下游泛型代码可以同时接收两者。
+*/
+
+// Sequence 单序列和双序列共同实现的中间结构接口
+// 索引均为 T R T R ... T 平铺后的位置
+type Sequence interface {
+	Len() int            // 平铺后的基元总数
+	At(i int) CmlElement // 平铺索引 i 处的基元
+	EncodeA() (string, error)
+	EncodeC() (string, error)
+	EncodeP() (string, error)
+	EncodeQ() (string, error)
+	IsValid() error
+}
+
+// 编译期检查两种结构都实现了 Sequence
+var (
+	_ Sequence = (*CmlFragments)(nil)
+	_ Sequence = (*CmlElements)(nil)
+)
+
+/**
+------------------------ 双序列 --------------------------
+*/
+
+// Len 平铺后的基元总数，合法序列恒为奇数
+func (f *CmlFragments) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.Tokens) + len(f.Relations)
+}
+
+// At 返回平铺索引 i 处的基元，偶数位是token，奇数位是关系符
+func (f *CmlFragments) At(i int) CmlElement {
+	if i%2 == 0 {
+		return CmlElement{Type: TypeToken, Value: f.Tokens[i/2]}
+	}
+	return CmlElement{Type: TypeSeparator, Value: f.Relations[i/2]}
+}
+
+// ToSingle 无损转换为基元类型抽象的单序列，转换前校验交替规律
+func (f *CmlFragments) ToSingle() (*CmlElements, error) {
+	if err := f.IsValid(); err != nil {
+		return nil, err
+	}
+	elements := make(CmlElements, 0, f.Len())
+	for i, token := range f.Tokens {
+		elements = append(elements, &CmlElement{Type: TypeToken, Value: token})
+		if i < len(f.Relations) {
+			elements = append(elements, &CmlElement{Type: TypeSeparator, Value: f.Relations[i]})
+		}
+	}
+	return &elements, nil
+}
+
+/**
+------------------------ 单序列 --------------------------
+*/
+
+// Len 基元总数
+func (elements *CmlElements) Len() int {
+	if elements == nil {
+		return 0
+	}
+	return len(*elements)
+}
+
+// At 返回索引 i 处的基元副本
+func (elements *CmlElements) At(i int) CmlElement {
+	return *(*elements)[i]
+}
+
+// ToDouble 无损转换为基元类型分类的双序列，转换前校验交替规律
+func (elements *CmlElements) ToDouble() (*CmlFragments, error) {
+	if err := elements.IsValid(); err != nil {
+		return nil, err
+	}
+	n := len(*elements)
+	fragments := &CmlFragments{
+		Tokens:    make([]string, 0, n/2+1),
+		Relations: make([]string, 0, n/2),
+	}
+	for _, el := range *elements {
+		if el.Type == TypeToken {
+			fragments.Tokens = append(fragments.Tokens, el.Value)
+		} else {
+			fragments.Relations = append(fragments.Relations, el.Value)
+		}
+	}
+	return fragments, nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+/**
+--- 编码前校验 ---
+所有编码器在输出前执行与严格模式解码相同的规则，保证 IsCML(EncodeX(x)) == nil，
+并且输出同时满足严格模式解码；严格模式解码得到的片段都可以重新编码。
+非严格解码为兼容旧数据接受非法 UTF-8 的token，这样的片段重新编码会返回 EncodeInvalidUTF8：
+1、结构：非nil、非空，token与关系符严格交替
+2、关系符：规范定义的5个单字节符号
+3、token：非空且为合法的 UTF-8
+*/
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// EncodeErrorKind 编码前校验失败的类别
+type EncodeErrorKind int
+
+const (
+	EncodeInvalidStructure EncodeErrorKind = iota + 1 // nil、空序列或token与关系符不交替
+	EncodeInvalidRelation                             // 非法的关系符
+	EncodeEmptyToken                                  // 空token，解码器同样拒绝
+	EncodeInvalidUTF8                                 // token不是合法的 UTF-8，只有严格模式解码会拒绝
+)
+
+var encodeErrorKindNames = map[EncodeErrorKind]string{
+	EncodeInvalidStructure: "非法结构",
+	EncodeInvalidRelation:  "非法关系符",
+	EncodeEmptyToken:       "空token",
+	EncodeInvalidUTF8:      "非法的UTF-8",
+}
+
+func (k EncodeErrorKind) String() string {
+	if name, ok := encodeErrorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("EncodeErrorKind(%d)", int(k))
+}
+
+// EncodeError 编码前校验失败，此时不产生任何输出
+type EncodeError struct {
+	Kind EncodeErrorKind
+	// Index 双序列中为token或关系符各自的索引，单序列中为基元索引；结构错误时为 -1
+	Index int
+	Value string // 出错的token或关系符
+	Msg   string // 结构错误的说明
+}
+
+func (e *EncodeError) Error() string {
+	if e.Kind == EncodeInvalidStructure {
+		return fmt.Sprintf("无法编码CML(%s): %s", e.Kind, e.Msg)
+	}
+	return fmt.Sprintf("无法编码CML(%s) 在索引 %d: %q", e.Kind, e.Index, e.Value)
+}
+
+func checkEncodeToken(i int, token string) error {
+	if token == "" {
+		return &EncodeError{Kind: EncodeEmptyToken, Index: i}
+	}
+	if !utf8.ValidString(token) {
+		return &EncodeError{Kind: EncodeInvalidUTF8, Index: i, Value: token}
+	}
+	return nil
+}
+
+// 双序列的编码前校验
+func (f *CmlFragments) validateEncode() error {
+	if err := f.IsValid(); err != nil {
+		// 关系符错误单独归类，其余均为结构错误
+		if f != nil && len(f.Tokens) == len(f.Relations)+1 {
+			for i, rel := range f.Relations {
+				if len(rel) != 1 || !Relation(rel[0]).IsValid() {
+					return &EncodeError{Kind: EncodeInvalidRelation, Index: i, Value: rel}
+				}
+			}
+		}
+		return &EncodeError{Kind: EncodeInvalidStructure, Index: -1, Msg: err.Error()}
+	}
+	for i, tok := range f.Tokens {
+		if err := checkEncodeToken(i, tok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 单序列的编码前校验
+func (elements *CmlElements) validateEncode() error {
+	if err := elements.IsValid(); err != nil {
+		if elements != nil && len(*elements)%2 == 1 {
+			for i, el := range *elements {
+				if el != nil && el.Type == TypeSeparator && i%2 == 1 &&
+					(len(el.Value) != 1 || !Relation(el.Value[0]).IsValid()) {
+					return &EncodeError{Kind: EncodeInvalidRelation, Index: i, Value: el.Value}
+				}
+			}
+		}
+		return &EncodeError{Kind: EncodeInvalidStructure, Index: -1, Msg: err.Error()}
+	}
+	for i, el := range *elements {
+		if el.Type == TypeToken {
+			if err := checkEncodeToken(i, el.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cml_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 迭代器测试：双序列与单序列的遍历结果应完全一致
+func TestCML_Iterators(t *testing.T) {
+	ast := require.New(t)
+
+	encoded, err := cml.Build("万有引力").Map("牛顿").Set("自然哲学的数学原理").Remark("1687 年").EncodeA()
+	ast.NoError(err)
+
+	double, err := cml.CML2Fragments(encoded)
+	ast.NoError(err)
+	single, err := cml.CML2Elements(encoded)
+	ast.NoError(err)
+
+	tokens := []string{"万有引力", "牛顿", "自然哲学的数学原理", "1687 年"}
+	ast.Equal(tokens, slices.Collect(double.TokenSeq()))
+	ast.Equal(tokens, slices.Collect(single.TokenSeq()))
+
+	triples := []cml.Triple{
+		{Left: "万有引力", Rel: cml.RelMapping, Right: "牛顿"},
+		{Left: "牛顿", Rel: cml.RelSet, Right: "自然哲学的数学原理"},
+		{Left: "自然哲学的数学原理", Rel: cml.RelRemark, Right: "1687 年"},
+	}
+	ast.Equal(triples, slices.Collect(double.Triples()))
+	ast.Equal(triples, slices.Collect(single.Triples()))
+
+	var fromDouble, fromSingle []cml.Element
+	for i, el := range double.All() {
+		ast.Equal(len(fromDouble), i)
+		fromDouble = append(fromDouble, el)
+	}
+	for _, el := range single.All() {
+		fromSingle = append(fromSingle, el)
+	}
+	ast.Equal(fromDouble, fromSingle)
+	ast.Len(fromDouble, 7)
+
+	var rels []cml.Relation
+	for rel, token := range double.Pairs() {
+		rels = append(rels, rel)
+		ast.NotEmpty(token)
+	}
+	ast.Equal(double.TypedRelations(), rels)
+
+	// 流式解码与整体解码一致
+	var streamed []cml.Element
+	for el, err := range cml.Elements(encoded) {
+		ast.NoError(err)
+		streamed = append(streamed, el)
+	}
+	ast.Equal(fromDouble, streamed)
+
+	// 提前 break 后不再继续产出
+	count := 0
+	for range cml.Elements(encoded) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	ast.Equal(2, count)
+
+	// 流式解码在出错位置产出错误，错误之前的基元正常产出
+	var seen int
+	var lastErr error
+	for el, err := range cml.Elements("pA:b*d!:B") {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		ast.NotEmpty(el.Value)
+		seen++
+	}
+	ast.Error(lastErr)
+	ast.Equal(2, seen)
+}
@@ -0,0 +1,106 @@
+package cml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 解码限制测试：三项限制在单序列、双序列和流式解码中一致生效
+func TestCML_DecodeLimits(t *testing.T) {
+	ast := require.New(t)
+
+	many := cml.Build("t")
+	for range 9 {
+		many.Line("t")
+	}
+	long := cml.Build(strings.Repeat("长", 100))
+
+	cases := []struct {
+		name  string
+		f     *cml.CMLDouble
+		opts  *cml.DecodeOptions
+		limit string
+	}{
+		{"输入长度", many, &cml.DecodeOptions{MaxInputBytes: 10}, "MaxInputBytes"},
+		{"token个数", many, &cml.DecodeOptions{MaxTokens: 9}, "MaxTokens"},
+		{"token长度", long, &cml.DecodeOptions{MaxTokenBytes: 299}, "MaxTokenBytes"},
+		{"token编码过长", long, &cml.DecodeOptions{MaxTokenBytes: 10}, "MaxTokenBytes"},
+	}
+	for _, c := range cases {
+		for _, mode := range []uint8{cml.ModeA, cml.ModeC, cml.ModeQ, cml.ModeP} {
+			encoded, err := c.f.Encode(mode)
+			ast.NoError(err)
+
+			_, err = cml.CML2FragmentsWith(encoded, c.opts)
+			var le *cml.LimitError
+			ast.True(errors.As(err, &le), "%s/%c 双序列: %v", c.name, mode, err)
+			ast.Equal(c.limit, le.Limit)
+			ast.Greater(le.Actual, le.Max)
+
+			_, err = cml.CML2ElementsWith(encoded, c.opts)
+			ast.True(errors.As(err, &le), "%s/%c 单序列: %v", c.name, mode, err)
+
+			var seqErr error
+			for _, err := range cml.ElementsWith(encoded, c.opts) {
+				if err != nil {
+					seqErr = err
+				}
+			}
+			ast.True(errors.As(seqErr, &le), "%s/%c 流式: %v", c.name, mode, seqErr)
+		}
+	}
+
+	// 恰好等于限制时可以正常解码
+	encoded, err := many.EncodeA()
+	ast.NoError(err)
+	f, err := cml.CML2FragmentsWith(encoded, &cml.DecodeOptions{MaxInputBytes: len(encoded), MaxTokens: 10, MaxTokenBytes: 1})
+	ast.NoError(err)
+	ast.Len(f.Tokens, 10)
+
+	// 缺省不可信限制
+	opts := cml.UntrustedDecodeOptions()
+	ast.Equal(cml.UntrustedMaxTokens, opts.MaxTokens)
+	_, err = cml.CML2FragmentsWith("p"+strings.Repeat("x", cml.UntrustedMaxInputBytes), opts)
+	ast.ErrorAs(err, new(*cml.LimitError))
+}
+
+// 取消测试：已取消的 Context 使解码立即返回
+func TestCML_DecodeContext(t *testing.T) {
+	ast := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := &cml.DecodeOptions{Context: ctx}
+
+	_, err := cml.CML2FragmentsWith("p万有引力:牛顿", opts)
+	ast.ErrorIs(err, context.Canceled)
+	_, err = cml.CML2ElementsWith("p万有引力:牛顿", opts)
+	ast.ErrorIs(err, context.Canceled)
+	for _, err := range cml.ElementsWith("p万有引力:牛顿", opts) {
+		ast.ErrorIs(err, context.Canceled)
+	}
+
+	// 解码前检查通过，整体层的 Base58 解码过程中取消
+	encoded, err := cml.Build(strings.Repeat("万", 4096)).Map("牛顿").EncodeA()
+	ast.NoError(err)
+	_, err = cml.CML2FragmentsWith(encoded, &cml.DecodeOptions{Context: &cancelAfter{Context: context.Background(), n: 1}})
+	ast.ErrorIs(err, context.Canceled)
+}
+
+// 前 n 次检查时未取消，之后返回 context.Canceled
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n > 0 {
+		c.n--
+		return nil
+	}
+	return context.Canceled
+}
@@ -0,0 +1,524 @@
+package cml
+
+/**
+基于结构体标签的 Go 值与CML互转，不列入语法内核:
+1、标签格式 `cml:"位置,关系符[,omitempty]"`，位置为整数，决定字段在片段中的顺序，同一结构体内不可重复
+2、关系符为连接该字段与前一个token的关系，可写符号 @ . + : 或名称 remark linear set mapping combine；
+   位置最小的字段可以省略关系符，片段的首个token没有前导关系符
+3、切片字段渲染为 + 集合，嵌套结构体展开到所在位置，其首个字段使用外层字段的关系符
+4、omitempty 字段为零值(空切片、nil 指针)时省略；Unmarshal 按回溯匹配决定每个字段占用哪些token
+示例:
+	type Discovery struct {
+		Subject string   `cml:"0"`
+		Author  string   `cml:"1,:"`
+		Works   []string `cml:"2,@"`
+		Year    int      `cml:"3,.,omitempty"`
+	}
+*/
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 展开后的一个字段位，对应一个token或一个 + 集合
+type marshalSlot struct {
+	name     string // 结构体名.字段路径，用于错误信息
+	index    []int  // 字段索引路径，经过的指针在赋值时自动分配
+	rel      Relation
+	optional bool
+	set      bool // 切片字段
+}
+
+// 结构体类型 -> 展开后的字段位，缓存复用
+var marshalPlans sync.Map
+
+func planOf(t reflect.Type) ([]marshalSlot, error) {
+	if cached, ok := marshalPlans.Load(t); ok {
+		return cached.([]marshalSlot), nil
+	}
+	slots, err := buildPlan(t, t.Name(), nil, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("cml: %s 没有带 cml 标签的字段", t)
+	}
+	marshalPlans.Store(t, slots)
+	return slots, nil
+}
+
+func buildPlan(t reflect.Type, prefix string, index []int, visiting map[reflect.Type]bool) ([]marshalSlot, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("cml: %s 递归嵌套了自身", prefix)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	type tagged struct {
+		pos       int
+		field     reflect.StructField
+		rel       Relation
+		omitempty bool
+	}
+	var fields []tagged
+	seen := map[int]string{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("cml")
+		if !ok || tag == "-" {
+			continue
+		}
+		name := prefix + "." + sf.Name
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("cml: %s 未导出，不能使用 cml 标签", name)
+		}
+		parts := strings.Split(tag, ",")
+		pos, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("cml: %s 的标签位置非法: %q", name, parts[0])
+		}
+		if other, dup := seen[pos]; dup {
+			return nil, fmt.Errorf("cml: %s 与 %s 的标签位置重复: %d", name, other, pos)
+		}
+		seen[pos] = name
+		tf := tagged{pos: pos, field: sf}
+		if len(parts) > 1 && parts[1] != "" {
+			if tf.rel, err = parseTagRelation(parts[1]); err != nil {
+				return nil, fmt.Errorf("cml: %s: %w", name, err)
+			}
+		}
+		for _, opt := range parts[min(2, len(parts)):] {
+			if strings.TrimSpace(opt) != "omitempty" {
+				return nil, fmt.Errorf("cml: %s 的标签选项未知: %q", name, opt)
+			}
+			tf.omitempty = true
+		}
+		fields = append(fields, tf)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].pos < fields[j].pos })
+
+	var slots []marshalSlot
+	for k, tf := range fields {
+		name := prefix + "." + tf.field.Name
+		if k > 0 && tf.rel == 0 {
+			return nil, fmt.Errorf("cml: %s 缺少关系符", name)
+		}
+		path := append(append([]int(nil), index...), tf.field.Index...)
+		ft := tf.field.Type
+		optional := tf.omitempty
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case isTextType(ft):
+			slots = append(slots, marshalSlot{name: name, index: path, rel: tf.rel, optional: optional})
+		case ft.Kind() == reflect.Struct:
+			sub, err := buildPlan(ft, name, path, visiting)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) == 0 {
+				return nil, fmt.Errorf("cml: %s 没有带 cml 标签的字段", name)
+			}
+			sub[0].rel = tf.rel
+			for i := range sub {
+				sub[i].optional = sub[i].optional || optional
+			}
+			slots = append(slots, sub...)
+		case ft.Kind() == reflect.Slice && isTextType(ft.Elem()):
+			slots = append(slots, marshalSlot{name: name, index: path, rel: tf.rel, optional: optional, set: true})
+		default:
+			return nil, fmt.Errorf("cml: %s 的类型 %s 不受支持", name, tf.field.Type)
+		}
+	}
+	return slots, nil
+}
+
+// 标签中的关系符：符号或语义名称
+func parseTagRelation(s string) (Relation, error) {
+	if len(s) == 1 {
+		return ParseRelation(s[0])
+	}
+	for _, rel := range []Relation{RelRemark, RelLinear, RelSet, RelMapping, RelCombine} {
+		if rel.Name() == s {
+			return rel, nil
+		}
+	}
+	return 0, fmt.Errorf("非法的关系符: %q", s)
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// 可以与单个token互转的类型
+func isTextType(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) && t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func structValue(v any, op string) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("cml: %s 的参数为 nil", op)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("cml: %s 只支持结构体，实际为 %s", op, rv.Type())
+	}
+	return rv, nil
+}
+
+/**
+------------------------ Marshal --------------------------
+*/
+
+// Marshal 按 cml 标签将结构体编码为指定模式的CML字符串
+func Marshal(v any, mode uint8) (string, error) {
+	f, err := MarshalFragments(v)
+	if err != nil {
+		return "", err
+	}
+	return f.Encode(mode)
+}
+
+// MarshalFragments 按 cml 标签将结构体转换为双序列
+func MarshalFragments(v any) (*CMLDouble, error) {
+	rv, err := structValue(v, "Marshal")
+	if err != nil {
+		return nil, err
+	}
+	slots, err := planOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	f := &CMLDouble{Relations: []string{}}
+	add := func(rel Relation, token string) {
+		if len(f.Tokens) > 0 {
+			f.Relations = append(f.Relations, string(rel.Symbol()))
+		}
+		f.Tokens = append(f.Tokens, token)
+	}
+	for _, s := range slots {
+		fv, ok := fieldOf(rv, s.index)
+		if ok && fv.Kind() == reflect.Pointer {
+			ok = !fv.IsNil()
+			if ok {
+				fv = fv.Elem()
+			}
+		}
+		if !ok || (s.optional && (fv.IsZero() || (s.set && fv.Len() == 0))) {
+			if s.optional {
+				continue
+			}
+			return nil, fmt.Errorf("cml: %s 为 nil", s.name)
+		}
+		if !s.set {
+			text, err := toText(fv)
+			if err != nil {
+				return nil, fmt.Errorf("cml: %s: %w", s.name, err)
+			}
+			if text == "" {
+				return nil, fmt.Errorf("cml: %s 为空，无法编码为token", s.name)
+			}
+			add(s.rel, text)
+			continue
+		}
+		if fv.Len() == 0 {
+			return nil, fmt.Errorf("cml: %s 为空集合", s.name)
+		}
+		for i := 0; i < fv.Len(); i++ {
+			text, err := toText(fv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("cml: %s[%d]: %w", s.name, i, err)
+			}
+			if text == "" {
+				return nil, fmt.Errorf("cml: %s[%d] 为空，无法编码为token", s.name, i)
+			}
+			rel := s.rel
+			if i > 0 {
+				rel = RelSet
+			}
+			add(rel, text)
+		}
+	}
+	if len(f.Tokens) == 0 {
+		return nil, fmt.Errorf("cml: %s 的全部字段均被省略", rv.Type())
+	}
+	return f, nil
+}
+
+// 按索引路径取字段，路径上遇到 nil 指针时返回 false
+func fieldOf(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func toText(v reflect.Value) (string, error) {
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("类型 %s 不受支持", v.Type())
+}
+
+/**
+------------------------ Unmarshal --------------------------
+*/
+
+// ShapeError 解码出的片段结构与结构体标签不匹配
+type ShapeError struct {
+	Type     reflect.Type
+	Index    int    // 能够匹配到的最远token索引
+	Expected string // 该位置期望的字段及关系符
+	Got      string // 该位置实际的关系符与token，片段提前结束时为空
+}
+
+func (e *ShapeError) Error() string {
+	got := e.Got
+	if got == "" {
+		got = "片段结束"
+	}
+	return fmt.Sprintf("cml: 片段结构与 %s 不匹配: token %d 处期望 %s，实际为 %s", e.Type, e.Index, e.Expected, got)
+}
+
+// Unmarshal 解码CML字符串，并按 cml 标签写入 v 指向的结构体
+func Unmarshal(encoded string, v any) error {
+	f, err := CML2Fragments(encoded)
+	if err != nil {
+		return err
+	}
+	return UnmarshalFragments(f, v)
+}
+
+// UnmarshalFragments 按 cml 标签将双序列写入 v 指向的结构体
+// 结构不匹配时返回 *ShapeError；任何错误都不修改 v 及其指向的嵌套结构体，
+// 成功时路径上的嵌套结构体指针替换为新分配的副本
+func UnmarshalFragments(f *CMLDouble, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("cml: Unmarshal 需要非 nil 的结构体指针")
+	}
+	rv, err := structValue(v, "Unmarshal")
+	if err != nil {
+		return err
+	}
+	if err := f.IsValid(); err != nil {
+		return err
+	}
+	slots, err := planOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	m := &shapeMatcher{slots: slots, f: f, failed: map[[2]int]bool{}, spans: make([][2]int, len(slots))}
+	if !m.match(0, 0) {
+		e := &ShapeError{Type: rv.Type(), Index: m.farTok, Expected: m.expected()}
+		if m.farTok < len(f.Tokens) {
+			e.Got = f.Tokens[m.farTok]
+			if m.farTok > 0 {
+				e.Got = fmt.Sprintf("%s %q", f.Relations[m.farTok-1], e.Got)
+			}
+		}
+		return e
+	}
+
+	// 先全部解析，成功后再写入，避免出错时留下部分赋值
+	type assign struct {
+		slot   marshalSlot
+		tokens []string
+	}
+	var assigns []assign
+	for i, s := range slots {
+		if span := m.spans[i]; span[1] > span[0] {
+			assigns = append(assigns, assign{s, f.Tokens[span[0]:span[1]]})
+		}
+	}
+	staged := reflect.New(rv.Type()).Elem()
+	staged.Set(rv)
+	cloned := map[string]bool{}
+	for _, a := range assigns {
+		fv := allocField(staged, a.slot.index, cloned)
+		if fv.Kind() == reflect.Pointer {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if !a.slot.set {
+			if err := fromText(fv, a.tokens[0]); err != nil {
+				return fmt.Errorf("cml: %s: %w", a.slot.name, err)
+			}
+			continue
+		}
+		list := reflect.MakeSlice(fv.Type(), len(a.tokens), len(a.tokens))
+		for i, tok := range a.tokens {
+			if err := fromText(list.Index(i), tok); err != nil {
+				return fmt.Errorf("cml: %s[%d]: %w", a.slot.name, i, err)
+			}
+		}
+		fv.Set(list)
+	}
+	rv.Set(staged)
+	return nil
+}
+
+// 按索引路径取可写的字段，路径上的指针替换为新分配的副本，
+// 暂存值因此不会写穿调用方已有的嵌套结构体；cloned 记录已替换过的路径前缀
+func allocField(v reflect.Value, index []int, cloned map[string]bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if key := fmt.Sprint(index[:i]); !cloned[key] {
+				fresh := reflect.New(v.Type().Elem())
+				if !v.IsNil() {
+					fresh.Elem().Set(v.Elem())
+				}
+				v.Set(fresh)
+				cloned[key] = true
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func fromText(v reflect.Value, text string) error {
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("类型 %s 不受支持", v.Type())
+	}
+	return nil
+}
+
+// 回溯匹配：为每个字段位分配连续的token区间
+// 可选字段先尝试占用再尝试省略，集合先尝试最长再逐个缩短；失败的 (字段位, token) 状态被记录，避免重复搜索
+type shapeMatcher struct {
+	slots  []marshalSlot
+	f      *CMLDouble
+	failed map[[2]int]bool
+	spans  [][2]int // 每个字段位占用的token区间 [start, end)
+
+	farTok, farSlot int // 匹配到的最远位置，用于错误信息
+}
+
+func (m *shapeMatcher) match(slot, tok int) bool {
+	if tok > m.farTok || (tok == m.farTok && slot > m.farSlot) {
+		m.farTok, m.farSlot = tok, slot
+	}
+	if slot == len(m.slots) {
+		return tok == len(m.f.Tokens)
+	}
+	key := [2]int{slot, tok}
+	if m.failed[key] {
+		return false
+	}
+	s := m.slots[slot]
+	if m.joins(s.rel, tok) {
+		end := tok + 1
+		if s.set {
+			for end < len(m.f.Tokens) && m.f.RelationAt(end-1) == RelSet {
+				end++
+			}
+		}
+		for ; end > tok; end-- {
+			m.spans[slot] = [2]int{tok, end}
+			if m.match(slot+1, end) {
+				return true
+			}
+		}
+	}
+	if s.optional {
+		m.spans[slot] = [2]int{tok, tok}
+		if m.match(slot+1, tok) {
+			return true
+		}
+	}
+	m.failed[key] = true
+	return false
+}
+
+// tok 处的token能否以关系符 rel 开始一个字段；首个token没有前导关系符
+func (m *shapeMatcher) joins(rel Relation, tok int) bool {
+	if tok >= len(m.f.Tokens) {
+		return false
+	}
+	return tok == 0 || m.f.RelationAt(tok-1) == rel
+}
+
+func (m *shapeMatcher) expected() string {
+	if m.farSlot >= len(m.slots) {
+		return "片段结束"
+	}
+	s := m.slots[m.farSlot]
+	if m.farTok == 0 {
+		return s.name
+	}
+	return fmt.Sprintf("%s (%c %s)", s.name, s.rel.Symbol(), s.rel.Name())
+}
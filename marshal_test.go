@@ -0,0 +1,160 @@
+package cml_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+type Discovery struct {
+	Subject string   `cml:"0"`
+	Author  string   `cml:"1,:"`
+	Works   []string `cml:"2,@"`
+	Year    int      `cml:"3,.,omitempty"`
+	Note    string   // 无标签字段不参与编码
+}
+
+type Place struct {
+	City    string `cml:"0"`
+	Country string `cml:"1,linear,omitempty"`
+}
+
+type Event struct {
+	Name  string    `cml:"0"`
+	Where *Place    `cml:"1,@,omitempty"`
+	When  time.Time `cml:"2,:"`
+	Tags  []string  `cml:"3,combine,omitempty"`
+	Done  *bool     `cml:"4,.,omitempty"`
+}
+
+// 结构体编解码测试：位置、关系符、集合、可选字段与嵌套结构体
+func TestCML_Marshal(t *testing.T) {
+	ast := require.New(t)
+
+	d := Discovery{Subject: "万有引力", Author: "牛顿", Works: []string{"原理", "光学"}, Year: 1687, Note: "x"}
+	p, err := cml.Marshal(d, cml.ModeP)
+	ast.NoError(err)
+	ast.Equal("p万有引力:牛顿@原理+光学.1687", p)
+
+	var back Discovery
+	ast.NoError(cml.Unmarshal(p, &back))
+	d.Note = ""
+	ast.Equal(d, back)
+
+	// omitempty 的零值被省略，且可以还原
+	d.Year = 0
+	p, err = cml.Marshal(&d, cml.ModeC)
+	ast.NoError(err)
+	back = Discovery{}
+	ast.NoError(cml.Unmarshal(p, &back))
+	ast.Equal(d, back)
+
+	// 嵌套结构体、指针、TextMarshaler
+	done := true
+	when := time.Date(1687, 7, 5, 0, 0, 0, 0, time.UTC)
+	e := Event{Name: "出版", Where: &Place{City: "伦敦", Country: "英国"}, When: when, Tags: []string{"科学", "物理"}, Done: &done}
+	f, err := cml.MarshalFragments(e)
+	ast.NoError(err)
+	ast.Equal([]string{"出版", "伦敦", "英国", when.Format(time.RFC3339), "科学", "物理", "true"}, f.Tokens)
+	ast.Equal([]string{"@", ".", ":", " ", "+", "."}, f.Relations)
+	var eb Event
+	ast.NoError(cml.UnmarshalFragments(f, &eb))
+	ast.Equal(e.Where, eb.Where)
+	ast.True(e.When.Equal(eb.When))
+	ast.Equal(e.Tags, eb.Tags)
+	ast.True(*eb.Done)
+
+	// 省略嵌套结构体与集合
+	e2 := Event{Name: "出版", When: when}
+	s, err := cml.Marshal(e2, cml.ModeQ)
+	ast.NoError(err)
+	eb = Event{}
+	ast.NoError(cml.Unmarshal(s, &eb))
+	ast.Nil(eb.Where)
+	ast.Nil(eb.Tags)
+}
+
+// 回溯匹配：可选集合与后续字段使用相同关系符时仍能正确分配
+func TestCML_UnmarshalBacktrack(t *testing.T) {
+	ast := require.New(t)
+	type T struct {
+		A string   `cml:"0"`
+		B []string `cml:"1,:,omitempty"`
+		C string   `cml:"2,:"`
+	}
+	var v T
+	ast.NoError(cml.Unmarshal("pa:c", &v))
+	ast.Equal(T{A: "a", C: "c"}, v)
+	v = T{}
+	ast.NoError(cml.Unmarshal("pa:b1+b2:c", &v))
+	ast.Equal(T{A: "a", B: []string{"b1", "b2"}, C: "c"}, v)
+}
+
+// 错误测试：结构不匹配、非法标签、空值
+func TestCML_MarshalErrors(t *testing.T) {
+	ast := require.New(t)
+
+	var d Discovery
+	err := cml.Unmarshal("p万有引力:牛顿+莱布尼茨", &d)
+	var se *cml.ShapeError
+	ast.True(errors.As(err, &se), "%v", err)
+	ast.Equal(2, se.Index)
+	ast.Contains(se.Error(), "Works")
+	ast.Equal(Discovery{}, d)
+
+	err = cml.Unmarshal("p万有引力:牛顿@原理.1687.多余", &d)
+	ast.ErrorAs(err, &se)
+
+	err = cml.Unmarshal("p万有引力:牛顿@原理.不是数字", &d)
+	ast.Error(err)
+	ast.False(errors.As(err, &se))
+
+	// 出错时不能写穿已有的嵌套结构体指针
+	type inner struct {
+		A string `cml:"0"`
+		B int    `cml:"1,:"`
+	}
+	type outer struct {
+		N string `cml:"0"`
+		P *inner `cml:"1,@"`
+		C int    `cml:"2,."`
+	}
+	orig := &inner{A: "orig"}
+	o := outer{P: orig}
+	ast.Error(cml.Unmarshal("pnx@new:2.notint", &o))
+	ast.Same(orig, o.P)
+	ast.Equal(inner{A: "orig"}, *orig)
+	ast.Equal("", o.N)
+	ast.NoError(cml.Unmarshal("pnx@new:2.3", &o))
+	ast.Equal(outer{N: "nx", P: &inner{A: "new", B: 2}, C: 3}, o)
+	ast.Equal(inner{A: "orig"}, *orig)
+
+	ast.Error(cml.Unmarshal("pA", d))
+	_, err = cml.Marshal(Discovery{Subject: "A", Author: "B"}, cml.ModeP)
+	ast.Error(err, "非可选的空集合")
+	_, err = cml.Marshal(Discovery{Subject: "", Author: "B", Works: []string{"W"}}, cml.ModeP)
+	ast.Error(err, "空token")
+
+	type noRel struct {
+		A string `cml:"0"`
+		B string `cml:"1"`
+	}
+	_, err = cml.Marshal(noRel{"a", "b"}, cml.ModeP)
+	ast.ErrorContains(err, "缺少关系符")
+
+	type dupPos struct {
+		A string `cml:"0"`
+		B string `cml:"0,:"`
+	}
+	_, err = cml.Marshal(dupPos{"a", "b"}, cml.ModeP)
+	ast.ErrorContains(err, "重复")
+
+	type badType struct {
+		A map[string]string `cml:"0"`
+	}
+	_, err = cml.Marshal(badType{}, cml.ModeP)
+	ast.ErrorContains(err, "不受支持")
+}
@@ -0,0 +1,116 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 嵌套CML测试：(牛顿 + 莱布尼茨) : 微积分，在四种模式下都能展开
+func TestCML_Nest(t *testing.T) {
+	ast := require.New(t)
+
+	group, err := cml.Nest(cml.Build("牛顿").Set("莱布尼茨"))
+	ast.NoError(err)
+	ast.True(cml.IsNested(group))
+	ast.False(cml.IsNested("!普通token!"))
+
+	outer := cml.Build(group).Map("微积分")
+	for _, encode := range []func() (string, error){outer.EncodeA, outer.EncodeC, outer.EncodeP, outer.EncodeQ} {
+		encoded, err := encode()
+		ast.NoError(err)
+
+		tree, err := cml.CML2Tree(encoded, 0)
+		ast.NoError(err)
+		ast.Equal(1, tree.Depth())
+		ast.NotNil(tree.Nodes[0].Sub)
+		ast.Nil(tree.Nodes[1].Sub)
+		ast.Equal([]string{":"}, tree.Relations)
+		ast.Equal("莱布尼茨", tree.Nodes[0].Sub.Nodes[1].Token)
+
+		back, err := tree.Fragments()
+		ast.NoError(err)
+		ast.Equal(outer, back)
+	}
+
+	// 深度限制
+	deep := cml.Build("核心")
+	for range 3 {
+		token, err := cml.Nest(deep)
+		ast.NoError(err)
+		deep = cml.Build(token).Remark("层")
+	}
+	encoded, err := deep.EncodeC()
+	ast.NoError(err)
+	tree, err := cml.CML2Tree(encoded, 3)
+	ast.NoError(err)
+	ast.Equal(3, tree.Depth())
+	_, err = cml.CML2Tree(encoded, 2)
+	ast.Error(err)
+
+	sub, err := cml.Unnest(group)
+	ast.NoError(err)
+	ast.Equal([]string{"牛顿", "莱布尼茨"}, sub.Tokens)
+}
+
+// 形似嵌套的普通token经过转义，按树解码后保持原值
+func TestCML_NestLiteral(t *testing.T) {
+	ast := require.New(t)
+	group, err := cml.Nest(cml.Build("A").Set("B"))
+	ast.NoError(err)
+
+	literals := []string{"!pA!", "!!x", "!!!", "!a0!", "!单独"}
+	tree := &cml.CMLTree{Relations: []string{":", ":", ":", ":", "@"}}
+	for _, lit := range literals {
+		tree.Nodes = append(tree.Nodes, &cml.TreeNode{Token: lit})
+	}
+	sub, err := cml.CML2Tree("pA+B", 0)
+	ast.NoError(err)
+	tree.Nodes = append(tree.Nodes, &cml.TreeNode{Token: group, Sub: sub})
+
+	f, err := tree.Fragments()
+	ast.NoError(err)
+	ast.Equal([]string{"!!pA!", "!!!x", "!!!!", "!a0!", "!单独", group}, f.Tokens)
+	for _, mode := range allModes {
+		encoded, err := f.Encode(mode)
+		ast.NoError(err)
+		back, err := cml.CML2Tree(encoded, 0)
+		ast.NoError(err)
+		for i, lit := range literals {
+			ast.Equal(lit, back.Nodes[i].Token)
+			ast.Nil(back.Nodes[i].Sub, lit)
+		}
+		ast.NotNil(back.Nodes[len(literals)].Sub)
+		again, err := back.Fragments()
+		ast.NoError(err)
+		ast.Equal(f, again)
+	}
+
+	ast.Equal("!!pA!", cml.EscapeLiteral("!pA!"))
+	ast.Equal("普通", cml.EscapeLiteral("普通"))
+	ast.Equal("!pA!", cml.UnescapeLiteral(cml.EscapeLiteral("!pA!")))
+	ast.Equal(group, cml.UnescapeLiteral(group))
+}
+
+// 嵌套子CML按调用方的解码选项解码，限制不会被内层绕过
+func TestCML_NestWith(t *testing.T) {
+	ast := require.New(t)
+	inner := cml.Build("A")
+	for range 9 {
+		inner = inner.Set("B")
+	}
+	group, err := cml.Nest(inner)
+	ast.NoError(err)
+	encoded, err := cml.Build(group).Map("C").EncodeA()
+	ast.NoError(err)
+
+	tree, err := cml.CML2TreeWith(encoded, 0, cml.UntrustedDecodeOptions())
+	ast.NoError(err)
+	ast.Len(tree.Nodes[0].Sub.Nodes, 10)
+
+	var le *cml.LimitError
+	_, err = cml.CML2TreeWith(encoded, 0, &cml.DecodeOptions{MaxTokens: 5})
+	ast.ErrorAs(err, &le)
+	ast.Equal("MaxTokens", le.Limit)
+}
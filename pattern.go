@@ -0,0 +1,360 @@
+package cml
+
+/**
+模式匹配不列入语法内核，是基于双序列的上层查询能力:
+1、模式同样遵循 token 关系符 token 的交替规律，项与项之间以空白分隔
+2、token位：*(任意)、"带引号的字面量"、裸字面量、/正则/、?名称 或 ?名称=匹配器(捕获)
+3、关系符位：@ . + : 四个符号、_ 表示组合关系(空格)、* 表示任意关系、[:+] 表示备选
+4、锚点：开头的 ^ 要求从首个token开始，结尾的 $ 要求到末尾token结束，缺省为任意连续片段
+示例：* : 牛顿 @ /\d{4}/    ^ ?主体 [:+] ?客体=/^牛/ $
+*/
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pattern 编译后的模式，可在热循环中并发复用
+type Pattern struct {
+	src         string
+	anchorStart bool
+	anchorEnd   bool
+	tokens      []tokenMatcher
+	relations   []relationMatcher
+}
+
+// MatchResult 一次匹配的结果
+type MatchResult struct {
+	Start    int               // 匹配片段的首个token索引
+	End      int               // 匹配片段末尾token的下一个索引，即区间 [Start, End)
+	Captures map[string]string // 捕获名称 -> 绑定的token
+}
+
+// token位匹配器
+type tokenMatcher struct {
+	capture string         // 捕获名称，空串表示不捕获
+	any     bool           // *，匹配任意token
+	literal string         // 字面量，精确相等
+	re      *regexp.Regexp // 正则，非锚定的查找语义，需要整体匹配请在正则内使用 ^$
+}
+
+func (m *tokenMatcher) match(token string) bool {
+	switch {
+	case m.any:
+		return true
+	case m.re != nil:
+		return m.re.MatchString(token)
+	default:
+		return m.literal == token
+	}
+}
+
+// 关系符位匹配器，集合为空表示任意关系
+type relationMatcher struct {
+	allowed string
+}
+
+func (m relationMatcher) match(rel string) bool {
+	if m.allowed == "" {
+		return true
+	}
+	return len(rel) == 1 && strings.IndexByte(m.allowed, rel[0]) >= 0
+}
+
+// CompilePattern 编译模式表达式
+func CompilePattern(expr string) (*Pattern, error) {
+	items, err := lexPattern(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pattern{src: expr}
+	if len(items) > 0 && items[0].isBare("^") {
+		p.anchorStart = true
+		items = items[1:]
+	}
+	if n := len(items); n > 0 && items[n-1].isBare("$") {
+		p.anchorEnd = true
+		items = items[:n-1]
+	}
+	if len(items) == 0 {
+		return nil, errors.New("模式语法错误: 模式中没有任何token匹配项")
+	}
+	if len(items)%2 == 0 {
+		return nil, errors.New("模式语法错误: 模式必须以token匹配项开头和结尾，与关系符交替出现")
+	}
+	for i, it := range items {
+		if i%2 == 0 {
+			m, err := parseTokenMatcher(it)
+			if err != nil {
+				return nil, err
+			}
+			p.tokens = append(p.tokens, m)
+		} else {
+			m, err := parseRelationMatcher(it)
+			if err != nil {
+				return nil, err
+			}
+			p.relations = append(p.relations, m)
+		}
+	}
+	return p, nil
+}
+
+// MustCompilePattern 编译模式表达式，失败时 panic，适合包级变量初始化
+func MustCompilePattern(expr string) *Pattern {
+	p, err := CompilePattern(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String 返回模式的源表达式
+func (p *Pattern) String() string {
+	return p.src
+}
+
+// Match 返回第一个匹配的片段，没有匹配时返回 nil, false
+func (p *Pattern) Match(f *CMLDouble) (*MatchResult, bool) {
+	for m := range p.scan(f) {
+		return m, true
+	}
+	return nil, false
+}
+
+// FindAll 返回所有起点上的匹配，匹配片段之间可能重叠
+func (p *Pattern) FindAll(f *CMLDouble) []*MatchResult {
+	var all []*MatchResult
+	for m := range p.scan(f) {
+		all = append(all, m)
+	}
+	return all
+}
+
+// 按起点依次尝试匹配
+func (p *Pattern) scan(f *CMLDouble) func(yield func(*MatchResult) bool) {
+	return func(yield func(*MatchResult) bool) {
+		if f == nil || f.IsValid() != nil {
+			return
+		}
+		n, k := len(f.Tokens), len(p.tokens)
+		if k > n {
+			return
+		}
+		first, last := 0, n-k
+		if p.anchorStart {
+			last = 0
+		}
+		if p.anchorEnd {
+			first = n - k
+		}
+		for start := first; start <= last; start++ {
+			if m := p.matchAt(f, start); m != nil {
+				if !yield(m) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// 在固定起点尝试匹配，同名捕获必须绑定相同的token
+func (p *Pattern) matchAt(f *CMLDouble, start int) *MatchResult {
+	var captures map[string]string
+	for i := range p.tokens {
+		tm := &p.tokens[i]
+		token := f.Tokens[start+i]
+		if !tm.match(token) {
+			return nil
+		}
+		if i > 0 && !p.relations[i-1].match(f.Relations[start+i-1]) {
+			return nil
+		}
+		if tm.capture == "" {
+			continue
+		}
+		if captures == nil {
+			captures = make(map[string]string)
+		}
+		if bound, ok := captures[tm.capture]; ok && bound != token {
+			return nil
+		}
+		captures[tm.capture] = token
+	}
+	return &MatchResult{Start: start, End: start + len(p.tokens), Captures: captures}
+}
+
+// Match 将模式匹配到CML编码上，返回第一个匹配，没有匹配时返回 nil, nil
+// 在循环中重复使用同一模式时，请先 CompilePattern
+func Match(pattern, encoded string) (*MatchResult, error) {
+	p, err := CompilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f, err := CML2Fragments(encoded)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := p.Match(f)
+	return m, nil
+}
+
+/**
+------------------------ 模式的词法与语法 --------------------------
+*/
+
+// 词法项，quoted/regex 表示来自定界项，不再具有特殊含义
+type patternItem struct {
+	text    string
+	quoted  bool
+	regex   bool
+	capture string // 捕获名称，来自 ?name 或 ?name= 前缀
+	pos     int    // 在源表达式中的字节位置，用于报错
+}
+
+// 以空白切分模式，识别 "..." 与 /.../ 两种定界项，以及 ?name= 捕获前缀
+func lexPattern(expr string) ([]patternItem, error) {
+	var items []patternItem
+	i := 0
+	for i < len(expr) {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		it := patternItem{pos: i}
+		if expr[i] == '?' {
+			j := i + 1
+			for j < len(expr) && expr[j] != '=' {
+				r, size := utf8.DecodeRuneInString(expr[j:])
+				if unicode.IsSpace(r) {
+					break
+				}
+				j += size
+			}
+			it.capture = expr[i+1 : j]
+			if it.capture == "" {
+				return nil, fmt.Errorf("模式语法错误: 位置 %d 处的捕获缺少名称", i)
+			}
+			if j >= len(expr) || expr[j] != '=' {
+				// ?name 捕获任意token
+				it.text = "*"
+				items = append(items, it)
+				i = j
+				continue
+			}
+			i = j + 1
+			if i >= len(expr) {
+				return nil, fmt.Errorf("模式语法错误: 位置 %d 处的捕获 %s 缺少匹配器", it.pos, it.capture)
+			}
+			if r, _ := utf8.DecodeRuneInString(expr[i:]); unicode.IsSpace(r) {
+				return nil, fmt.Errorf("模式语法错误: 位置 %d 处的捕获 %s 缺少匹配器", it.pos, it.capture)
+			}
+		}
+		if expr[i] == '"' || expr[i] == '/' {
+			text, end, err := lexDelimited(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			it.text, it.quoted, it.regex = text, expr[i] == '"', expr[i] == '/'
+			items = append(items, it)
+			i = end
+			continue
+		}
+		start := i
+		for i < len(expr) {
+			r, size := utf8.DecodeRuneInString(expr[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		it.text = expr[start:i]
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// 读取从 start 开始的定界项，支持反斜杠转义定界符
+// 引号字面量会去掉转义，正则保留转义交给 regexp 处理
+func lexDelimited(expr string, start int) (string, int, error) {
+	delim := expr[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(expr); i++ {
+		c := expr[i]
+		if c == '\\' && i+1 < len(expr) {
+			next := expr[i+1]
+			switch {
+			case next == delim:
+				sb.WriteByte(next)
+			case delim == '"' && next == '\\':
+				sb.WriteByte('\\')
+			default:
+				sb.WriteByte(c)
+				sb.WriteByte(next)
+			}
+			i++
+			continue
+		}
+		if c == delim {
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(c)
+	}
+	return "", 0, fmt.Errorf("模式语法错误: 位置 %d 处的 %c 未闭合", start, delim)
+}
+
+func parseTokenMatcher(it patternItem) (tokenMatcher, error) {
+	m := tokenMatcher{capture: it.capture}
+	switch {
+	case it.regex:
+		re, err := regexp.Compile(it.text)
+		if err != nil {
+			return m, fmt.Errorf("模式语法错误: 位置 %d 处的正则非法: %w", it.pos, err)
+		}
+		m.re = re
+	case it.quoted:
+		m.literal = it.text
+	case it.text == "*":
+		m.any = true
+	default:
+		m.literal = it.text
+	}
+	return m, nil
+}
+
+// 是否为指定内容的裸项，锚点和关系符只能以裸项出现
+func (it patternItem) isBare(text string) bool {
+	return !it.quoted && !it.regex && it.capture == "" && it.text == text
+}
+
+func parseRelationMatcher(it patternItem) (relationMatcher, error) {
+	if it.quoted || it.regex || it.capture != "" {
+		return relationMatcher{}, fmt.Errorf("模式语法错误: 位置 %d 处期望关系符，实际得到 %q", it.pos, it.text)
+	}
+	text := it.text
+	if text == "*" {
+		return relationMatcher{}, nil
+	}
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") && len(text) > 2 {
+		text = text[1 : len(text)-1]
+	} else if len(text) != 1 {
+		return relationMatcher{}, fmt.Errorf("模式语法错误: 位置 %d 处期望关系符，实际得到 %q", it.pos, it.text)
+	}
+	var allowed strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '_' {
+			c = ' '
+		}
+		if !Relation(c).IsValid() {
+			return relationMatcher{}, fmt.Errorf("模式语法错误: 位置 %d 处的关系符 %q 非法", it.pos, text[i])
+		}
+		allowed.WriteByte(c)
+	}
+	return relationMatcher{allowed: allowed.String()}, nil
+}
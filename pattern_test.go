@@ -0,0 +1,67 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 模式匹配测试
+func TestCML_Pattern(t *testing.T) {
+	encoded, err := cml.Build("万有引力").Map("牛顿").Remark("1687 年").Set("光学").EncodeQ()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		match    bool
+		start    int
+		captures map[string]string
+	}{
+		{name: "通配与正则", pattern: `* : 牛顿 @ /\d{4}/`, match: true, start: 0},
+		{name: "捕获", pattern: `?概念 : 牛顿 @ ?年份=/^\d{4}/`, match: true, start: 0,
+			captures: map[string]string{"概念": "万有引力", "年份": "1687 年"}},
+		{name: "关系备选", pattern: `牛顿 [@+] * [@+] 光学`, match: true, start: 1},
+		{name: "任意关系", pattern: `牛顿 * "1687 年"`, match: true, start: 1},
+		{name: "首锚点失败", pattern: `^ 牛顿 @ *`, match: false},
+		{name: "尾锚点", pattern: `?x + 光学 $`, match: true, start: 2,
+			captures: map[string]string{"x": "1687 年"}},
+		{name: "关系不符", pattern: `万有引力 . 牛顿`, match: false},
+		{name: "同名捕获必须一致", pattern: `?a * ?a`, match: false},
+		{name: "组合关系", pattern: `* _ *`, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := require.New(t)
+			m, err := cml.Match(tt.pattern, encoded)
+			ast.NoError(err)
+			if !tt.match {
+				ast.Nil(m)
+				return
+			}
+			ast.NotNil(m)
+			ast.Equal(tt.start, m.Start)
+			if tt.captures != nil {
+				ast.Equal(tt.captures, m.Captures)
+			}
+		})
+	}
+
+	t.Run("编译后复用", func(t *testing.T) {
+		ast := require.New(t)
+		p := cml.MustCompilePattern(`?left * ?right`)
+		f, err := cml.CML2Fragments(encoded)
+		ast.NoError(err)
+		all := p.FindAll(f)
+		ast.Len(all, 3)
+		ast.Equal("光学", all[2].Captures["right"])
+	})
+
+	t.Run("非法模式", func(t *testing.T) {
+		for _, bad := range []string{"", "A :", "A x B", `A : "B`, `A : /(/`, "? : B", "A : ?x="} {
+			_, err := cml.CompilePattern(bad)
+			require.Error(t, err, bad)
+		}
+	})
+}
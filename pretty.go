@@ -0,0 +1,243 @@
+package cml
+
+/**
+手写友好的明文语法不列入语法内核，用于配置文件和测试中直接书写CML:
+1、关系符 @ . + : 两侧可以有任意空白；两个token之间只有空白时，视为一个组合关系(空格)
+2、包含保留字符的token可以整体加双引号，如 "自然哲学.原理"，引号内支持 \" \\ \n \t 转义
+3、未加引号的token可以用反斜杠转义单个保留字符，如 自然哲学\.原理、1687\ 年
+4、换行与空格等价，首尾空白被忽略
+FormatPretty 输出的文本总能被 ParsePretty 还原为相同的双序列；明文语法只能表达 UTF-8 文本，
+token不是合法的 UTF-8 时 FormatPretty 返回错误，而不是输出无法还原的文本。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError 明文语法错误，行号和列号从1开始，列号按字符(rune)计
+type SyntaxError struct {
+	Line   int
+	Column int
+	Offset int // 字节偏移
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("CML语法错误(第%d行第%d列): %s", e.Line, e.Column, e.Msg)
+}
+
+// 明文语法的扫描器
+type prettyScanner struct {
+	src string
+	pos int
+}
+
+func (s *prettyScanner) errorf(offset int, format string, args ...any) error {
+	// 从头计算 offset 处的行列，只在出错时发生
+	line, col := 1, 1
+	for _, r := range s.src[:offset] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &SyntaxError{Line: line, Column: col, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// 当前位置是非法的 UTF-8 字节时返回带位置的错误，明文语法中没有它的写法
+func (s *prettyScanner) checkRune(r rune, size int) error {
+	if r == utf8.RuneError && size == 1 {
+		return s.errorf(s.pos, "非法的 UTF-8 字节 %#x", s.src[s.pos])
+	}
+	return nil
+}
+
+func (s *prettyScanner) peek() (rune, int) {
+	if s.pos >= len(s.src) {
+		return utf8.RuneError, 0
+	}
+	return utf8.DecodeRuneInString(s.src[s.pos:])
+}
+
+// 跳过空白，返回是否跳过了至少一个字符
+func (s *prettyScanner) skipSpace() bool {
+	start := s.pos
+	for {
+		r, size := s.peek()
+		if size == 0 || !unicode.IsSpace(r) {
+			return s.pos > start
+		}
+		s.pos += size
+	}
+}
+
+// 是否为明文语法中需要转义的字符
+func isPrettyReserved(r rune) bool {
+	return r == '"' || r == '\\' || (r < utf8.RuneSelf && Relation(r).IsValid()) || unicode.IsSpace(r)
+}
+
+// ParsePretty 解析手写明文语法，返回双序列，输入含非法的 UTF-8 字节时返回带位置的 SyntaxError
+func ParsePretty(src string) (*CMLDouble, error) {
+	s := &prettyScanner{src: src}
+	f := &CMLDouble{Relations: []string{}}
+	s.skipSpace()
+	if s.pos >= len(src) {
+		return nil, s.errorf(s.pos, "输入为空")
+	}
+	for {
+		token, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		f.Tokens = append(f.Tokens, token)
+
+		spaced := s.skipSpace()
+		if s.pos >= len(src) {
+			return f, nil
+		}
+		rel := RelCombine
+		if r, _ := s.peek(); r < utf8.RuneSelf && Relation(r).IsValid() {
+			rel = Relation(r)
+			s.pos++
+			s.skipSpace()
+			if s.pos >= len(src) {
+				return nil, s.errorf(s.pos-1, "关系符 %q 之后缺少token", rel.String())
+			}
+		} else if !spaced {
+			return nil, s.errorf(s.pos, "token之后缺少关系符")
+		}
+		f.Relations = append(f.Relations, rel.String())
+	}
+}
+
+// 读取一个token，引号形式或反斜杠转义形式
+func (s *prettyScanner) token() (string, error) {
+	start := s.pos
+	r, _ := s.peek()
+	if r == '"' {
+		return s.quoted()
+	}
+	var sb strings.Builder
+	for s.pos < len(s.src) {
+		r, size := s.peek()
+		if err := s.checkRune(r, size); err != nil {
+			return "", err
+		}
+		if r == '\\' {
+			if s.pos+size >= len(s.src) {
+				return "", s.errorf(s.pos, "反斜杠之后缺少字符")
+			}
+			s.pos += size
+			esc, size := s.peek()
+			if err := s.checkRune(esc, size); err != nil {
+				return "", err
+			}
+			sb.WriteString(unescapePretty(esc))
+			s.pos += size
+			continue
+		}
+		if r == '"' {
+			return "", s.errorf(s.pos, "引号只能包裹整个token")
+		}
+		if isPrettyReserved(r) {
+			break
+		}
+		sb.WriteRune(r)
+		s.pos += size
+	}
+	if s.pos == start {
+		r, _ := s.peek()
+		return "", s.errorf(s.pos, "期望token，实际得到 %q", r)
+	}
+	return sb.String(), nil
+}
+
+// 读取双引号token
+func (s *prettyScanner) quoted() (string, error) {
+	open := s.pos
+	s.pos++
+	var sb strings.Builder
+	for s.pos < len(s.src) {
+		r, size := s.peek()
+		if err := s.checkRune(r, size); err != nil {
+			return "", err
+		}
+		s.pos += size
+		switch r {
+		case '"':
+			if sb.Len() == 0 {
+				return "", s.errorf(open, "token不能是空串")
+			}
+			if next, size := s.peek(); size > 0 && (next == '"' || !isPrettyReserved(next)) {
+				return "", s.errorf(s.pos, "引号只能包裹整个token")
+			}
+			return sb.String(), nil
+		case '\\':
+			if s.pos >= len(s.src) {
+				return "", s.errorf(s.pos-1, "反斜杠之后缺少字符")
+			}
+			esc, size := s.peek()
+			if err := s.checkRune(esc, size); err != nil {
+				return "", err
+			}
+			sb.WriteString(unescapePretty(esc))
+			s.pos += size
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return "", s.errorf(open, "引号未闭合")
+}
+
+func unescapePretty(r rune) string {
+	switch r {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	}
+	return string(r)
+}
+
+// FormatPretty 将双序列输出为手写明文语法
+// 含保留字符、空白或引号的token整体加引号，关系符两侧各留一个空格，组合关系输出为单个空格
+// token为空串或不是合法的 UTF-8 时返回错误
+func FormatPretty(f *CMLDouble) (string, error) {
+	if err := f.IsValid(); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, token := range f.Tokens {
+		if token == "" {
+			return "", errors.New("CML明文输出失败: token不应为空串")
+		}
+		if !utf8.ValidString(token) {
+			return "", fmt.Errorf("CML明文输出失败: token %d 不是合法的UTF-8: %q", i, token)
+		}
+		if i > 0 {
+			if rel := f.RelationAt(i - 1); rel == RelCombine {
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteByte(' ')
+				sb.WriteByte(rel.Symbol())
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(quotePretty(token))
+	}
+	return sb.String(), nil
+}
+
+func quotePretty(token string) string {
+	if !strings.ContainsFunc(token, isPrettyReserved) {
+		return token
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(token) + `"`
+}
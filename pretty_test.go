@@ -0,0 +1,71 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 手写明文语法测试
+func TestCML_Pretty(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want *cml.CMLDouble
+	}{
+		{"关系符两侧空白", "万有引力 : 牛顿+原理 @ 1687", cml.Build("万有引力").Map("牛顿").Set("原理").Remark("1687")},
+		{"组合关系与换行", "  牛顿\n\t 莱布尼茨  ", cml.Build("牛顿").Combine("莱布尼茨")},
+		{"引号token", `"自然哲学.原理" : "1687 年"`, cml.Build("自然哲学.原理").Map("1687 年")},
+		{"引号内转义", `"say \"hi\"\\\n"`, cml.Build("say \"hi\"\\\n")},
+		{"反斜杠转义", `自然哲学\.原理 @ 1687\ 年`, cml.Build("自然哲学.原理").Remark("1687 年")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := require.New(t)
+			got, err := cml.ParsePretty(tt.src)
+			ast.NoError(err)
+			ast.Equal(tt.want, got)
+
+			// 输出后再解析应完全一致
+			text, err := cml.FormatPretty(got)
+			ast.NoError(err)
+			back, err := cml.ParsePretty(text)
+			ast.NoError(err)
+			ast.Equal(got, back)
+		})
+	}
+
+	ast := require.New(t)
+	text, err := cml.FormatPretty(cml.Build("万有引力").Map("a.b").Combine("c"))
+	ast.NoError(err)
+	ast.Equal(`万有引力 : "a.b" c`, text)
+	// 非法 UTF-8 无法在明文语法中还原
+	_, err = cml.FormatPretty(cml.Build("A").Map("\xff"))
+	ast.ErrorContains(err, "UTF-8")
+
+	errs := []struct {
+		src       string
+		line, col int
+	}{
+		{"", 1, 1},
+		{"@ A", 1, 1},
+		{"A :", 1, 3},
+		{"A\n: @ B", 2, 3},
+		{`A : "未闭合`, 1, 5},
+		{`A : ""`, 1, 5},
+		{`A : "b"c`, 1, 8},
+		{`A : b"c"`, 1, 6},
+		{`A\`, 1, 2},
+		{"A : b\xff", 1, 6},
+		{"A\n: \"b\xffc\"", 2, 5},
+		{"A : b\\\xff", 1, 7},
+	}
+	for _, e := range errs {
+		_, err := cml.ParsePretty(e.src)
+		var se *cml.SyntaxError
+		ast.ErrorAs(err, &se, e.src)
+		ast.Equal(e.line, se.Line, e.src)
+		ast.Equal(e.col, se.Column, e.src)
+	}
+}
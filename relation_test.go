@@ -0,0 +1,45 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 类型化关系符测试
+func TestCML_Relation(t *testing.T) {
+	ast := require.New(t)
+
+	tests := []struct {
+		symbol byte
+		rel    cml.Relation
+		name   string
+	}{
+		{'@', cml.RelRemark, "remark"},
+		{'.', cml.RelLinear, "linear"},
+		{'+', cml.RelSet, "set"},
+		{':', cml.RelMapping, "mapping"},
+		{' ', cml.RelCombine, "combine"},
+	}
+	for _, tt := range tests {
+		rel, err := cml.ParseRelation(tt.symbol)
+		ast.NoError(err)
+		ast.Equal(tt.rel, rel)
+		ast.True(rel.IsValid())
+		ast.Equal(tt.symbol, rel.Symbol())
+		ast.Equal(string(tt.symbol), rel.String())
+		ast.Equal(tt.name, rel.Name())
+	}
+
+	_, err := cml.ParseRelation('x')
+	ast.Error(err)
+	ast.False(cml.Relation('!').IsValid())
+	ast.Empty(cml.Relation('!').Name())
+
+	// 双序列通过访问器暴露类型化关系符
+	f, err := cml.CML2Fragments("p万有引力:牛顿+原理@1687")
+	ast.NoError(err)
+	ast.Equal([]cml.Relation{cml.RelMapping, cml.RelSet, cml.RelRemark}, f.TypedRelations())
+	ast.Equal(cml.RelSet, f.RelationAt(1))
+}
@@ -0,0 +1,99 @@
+package cml_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 修复解码测试：逐类修复并报告，合法输入不产生修复记录
+func TestCML_Repair(t *testing.T) {
+	ast := require.New(t)
+
+	cases := []struct {
+		name      string
+		encoded   string
+		tokens    []string
+		relations []string
+		kinds     []cml.RepairKind
+	}{
+		{"合法输入", "p万有引力:牛顿@1687", []string{"万有引力", "牛顿", "1687"}, []string{":", "@"}, nil},
+		{"连续关系符", "pA::B..C", []string{"A", "B", "C"}, []string{":", "."},
+			[]cml.RepairKind{cml.RepairEmptyToken, cml.RepairEmptyToken}},
+		{"空格包围的关系符", "pA : B", []string{"A", "B"}, []string{":"}, []cml.RepairKind{cml.RepairEmptyToken}},
+		{"首尾关系符", "p:A+B@", []string{"A", "B"}, []string{"+"},
+			[]cml.RepairKind{cml.RepairEdgeRelation, cml.RepairEdgeRelation}},
+		{"非法转义", "pHello!:世界", []string{"Hello!", "世界"}, []string{":"}, []cml.RepairKind{cml.RepairBadEscape}},
+		{"缺少模式前缀", "万有引力:牛顿", []string{"万有引力", "牛顿"}, []string{":"}, []cml.RepairKind{cml.RepairMissingMode}},
+		{"模式字母开头的明文", "apple:banana", []string{"apple", "banana"}, []string{":"}, []cml.RepairKind{cml.RepairMissingMode}},
+		{"单个字符", "a", []string{"a"}, []string{}, []cml.RepairKind{cml.RepairMissingMode}},
+		{"开头的空转义token", "p!:A", []string{"A"}, []string{},
+			[]cml.RepairKind{cml.RepairEmptyToken, cml.RepairEdgeRelation}},
+		{"末尾的空转义token", "pA:!", []string{"A"}, []string{},
+			[]cml.RepairKind{cml.RepairEmptyToken, cml.RepairEdgeRelation}},
+		{"中间的空转义token", "pA:!.B", []string{"A", "B"}, []string{":"}, []cml.RepairKind{cml.RepairEmptyToken}},
+		{"token无法按声明的模式解码", "c" + "QUA6Qg", []string{"QUA6Qg"}, []string{},
+			[]cml.RepairKind{cml.RepairModeFallback}},
+	}
+	for _, c := range cases {
+		f, fixes, err := cml.Repair(c.encoded)
+		ast.NoError(err, c.name)
+		ast.Equal(c.tokens, f.Tokens, c.name)
+		ast.Equal(c.relations, f.Relations, c.name)
+		kinds := make([]cml.RepairKind, 0, len(fixes))
+		for _, fix := range fixes {
+			kinds = append(kinds, fix.Kind)
+			ast.NotEmpty(fix.Message)
+		}
+		ast.ElementsMatch(c.kinds, kinds, c.name)
+		ast.NoError(f.IsValid(), c.name)
+		_, err = f.EncodeP()
+		ast.NoError(err, c.name)
+	}
+
+	// 修复结果可以重新编码，其它模式同样生效
+	f := cml.Build("A").Map("B")
+	encoded, err := f.EncodeQ()
+	ast.NoError(err)
+	got, fixes, err := cml.Repair(encoded)
+	ast.NoError(err)
+	ast.Empty(fixes)
+	ast.Equal(f.Tokens, got.Tokens)
+
+	_, _, err = cml.Repair(":: @")
+	ast.Error(err)
+	// 前缀合法时不把模式字母当作token
+	_, _, err = cml.Repair("p   ")
+	ast.Error(err)
+	_, _, err = cml.Repair("p!")
+	ast.Error(err)
+	_, _, err = cml.Repair("")
+	ast.Error(err)
+}
+
+// 按选项修复：限制和取消不会被回退解析吞掉
+func TestCML_RepairWith(t *testing.T) {
+	ast := require.New(t)
+
+	f, fixes, err := cml.RepairWith("pA::B..C", cml.UntrustedDecodeOptions())
+	ast.NoError(err)
+	ast.Equal([]string{"A", "B", "C"}, f.Tokens)
+	ast.Len(fixes, 2)
+
+	var le *cml.LimitError
+	_, _, err = cml.RepairWith("pA::B..C", &cml.DecodeOptions{MaxTokens: 2})
+	ast.ErrorAs(err, &le)
+	ast.Equal("MaxTokens", le.Limit)
+	_, _, err = cml.RepairWith("pA:"+strings.Repeat("长", 10), &cml.DecodeOptions{MaxTokenBytes: 8})
+	ast.ErrorAs(err, &le)
+	_, _, err = cml.RepairWith("万有引力:牛顿", &cml.DecodeOptions{MaxInputBytes: 8})
+	ast.ErrorAs(err, &le)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = cml.RepairWith("pA::B", &cml.DecodeOptions{Context: ctx})
+	ast.ErrorIs(err, context.Canceled)
+}
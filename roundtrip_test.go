@@ -0,0 +1,181 @@
+package cml_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+var allModes = []uint8{cml.ModeA, cml.ModeC, cml.ModeQ, cml.ModeP}
+
+// 编码要么返回 *EncodeError，要么输出可以被严格模式解码并还原为原序列
+func checkRoundTrip(t *testing.T, f *cml.CMLDouble) {
+	t.Helper()
+	for _, mode := range allModes {
+		encoded, err := f.Encode(mode)
+		if err != nil {
+			var ee *cml.EncodeError
+			if !errors.As(err, &ee) {
+				t.Fatalf("%c 模式返回了非 *EncodeError: %v", mode, err)
+			}
+			continue
+		}
+		if err := cml.IsCML(encoded); err != nil {
+			t.Fatalf("%c 模式输出 %q 无法解码: %v", mode, encoded, err)
+		}
+		back, err := cml.CML2FragmentsWith(encoded, &cml.DecodeOptions{Strict: true})
+		if err != nil {
+			t.Fatalf("%c 模式输出 %q 不是规范编码: %v", mode, encoded, err)
+		}
+		if !equalFragments(f, back) {
+			t.Fatalf("%c 模式往返不一致: %q -> %q", mode, f.Tokens, back.Tokens)
+		}
+
+		single, err := f.ToSingle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedSingle, err := encodeSingle(single, mode)
+		if err != nil || encodedSingle != encoded {
+			t.Fatalf("%c 模式单序列编码不一致: %q, %v", mode, encodedSingle, err)
+		}
+	}
+}
+
+func encodeSingle(s *cml.CMLSingle, mode uint8) (string, error) {
+	switch mode {
+	case cml.ModeA:
+		return s.EncodeA()
+	case cml.ModeC:
+		return s.EncodeC()
+	case cml.ModeQ:
+		return s.EncodeQ()
+	}
+	return s.EncodeP()
+}
+
+func equalFragments(a, b *cml.CMLDouble) bool {
+	if len(a.Tokens) != len(b.Tokens) || len(a.Relations) != len(b.Relations) {
+		return false
+	}
+	for i := range a.Tokens {
+		if a.Tokens[i] != b.Tokens[i] {
+			return false
+		}
+	}
+	for i := range a.Relations {
+		if a.Relations[i] != b.Relations[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 随机往返测试：混入空token、非法 UTF-8、保留字符和非法关系符
+func TestCML_EncodeRoundTrip(t *testing.T) {
+	ast := require.New(t)
+	r := rand.New(rand.NewPCG(48, 48))
+	pieces := []string{"", "\xff", "a!", "x y", "@", ".", "!", "中文", "😀", "\x00"}
+	rels := []string{"@", ".", "+", ":", " "}
+
+	rejected := 0
+	for range 2000 {
+		n := 1 + r.IntN(5)
+		f := &cml.CMLDouble{Relations: []string{}}
+		for i := range n {
+			tok := randomToken(r, r.IntN(6))
+			if r.IntN(4) == 0 {
+				tok = pieces[r.IntN(len(pieces))] + tok
+			}
+			f.Tokens = append(f.Tokens, tok)
+			if i < n-1 {
+				rel := rels[r.IntN(len(rels))]
+				if r.IntN(50) == 0 {
+					rel = "!"
+				}
+				f.Relations = append(f.Relations, rel)
+			}
+		}
+		if _, err := f.EncodeP(); err != nil {
+			rejected++
+		}
+		checkRoundTrip(t, f)
+	}
+	// 随机数据中应当同时存在被拒绝和被接受的序列
+	ast.Greater(rejected, 0)
+	ast.Less(rejected, 2000)
+}
+
+// 编码错误类别测试
+func TestCML_EncodeError(t *testing.T) {
+	ast := require.New(t)
+	cases := []struct {
+		f     *cml.CMLDouble
+		kind  cml.EncodeErrorKind
+		index int
+	}{
+		{nil, cml.EncodeInvalidStructure, -1},
+		{&cml.CMLDouble{Tokens: []string{"A", "B"}, Relations: []string{}}, cml.EncodeInvalidStructure, -1},
+		{&cml.CMLDouble{Tokens: []string{"A", "B", "C"}, Relations: []string{":", "!"}}, cml.EncodeInvalidRelation, 1},
+		{&cml.CMLDouble{Tokens: []string{"A", "", "C"}, Relations: []string{":", "."}}, cml.EncodeEmptyToken, 1},
+		{&cml.CMLDouble{Tokens: []string{"A\xff"}, Relations: []string{}}, cml.EncodeInvalidUTF8, 0},
+	}
+	for _, c := range cases {
+		for _, mode := range allModes {
+			_, err := c.f.Encode(mode)
+			var ee *cml.EncodeError
+			ast.True(errors.As(err, &ee), "%v", err)
+			ast.Equal(c.kind, ee.Kind)
+			ast.Equal(c.index, ee.Index)
+		}
+	}
+
+	// 单序列同样校验，索引为基元索引
+	single := cml.CMLSingle{
+		{Type: cml.TypeToken, Value: "A"},
+		{Type: cml.TypeSeparator, Value: ":"},
+		{Type: cml.TypeToken, Value: ""},
+	}
+	_, err := single.EncodeA()
+	var ee *cml.EncodeError
+	ast.ErrorAs(err, &ee)
+	ast.Equal(cml.EncodeEmptyToken, ee.Kind)
+	ast.Equal(2, ee.Index)
+}
+
+// 解码再编码：严格模式能解码的片段都能重新编码，空的转义token在解码时即被拒绝
+func TestCML_DecodeThenEncode(t *testing.T) {
+	ast := require.New(t)
+	for _, encoded := range []string{"pA:B", "pQUBC!:x", "qQTpC", "cNTRtYjZhR186ZUE"} {
+		f, err := cml.CML2FragmentsWith(encoded, &cml.DecodeOptions{Strict: true})
+		ast.NoError(err, encoded)
+		checkRoundTrip(t, f)
+	}
+	for _, encoded := range []string{"p!", "p!:A", "pA:!", "pA:B.!"} {
+		ast.Error(cml.IsCML(encoded), encoded)
+		_, err := cml.CML2FragmentsWith(encoded, &cml.DecodeOptions{Strict: true})
+		ast.Error(err, encoded)
+	}
+
+	// 非严格解码接受非法 UTF-8，重新编码时报告
+	f, err := cml.CML2Fragments("cX180OmVB")
+	ast.NoError(err)
+	_, err = f.EncodeP()
+	var ee *cml.EncodeError
+	ast.ErrorAs(err, &ee)
+	ast.Equal(cml.EncodeInvalidUTF8, ee.Kind)
+}
+
+// 模糊测试：任意两个token与关系符，编码结果必须可以解码并还原
+func FuzzCML_EncodeRoundTrip(f *testing.F) {
+	f.Add("万有引力", byte(':'), "牛顿")
+	f.Add("a!", byte('@'), "x y")
+	f.Add("", byte('.'), "\xff")
+	f.Add("\x00\x00", byte(' '), "1")
+	f.Fuzz(func(t *testing.T, left string, rel byte, right string) {
+		checkRoundTrip(t, &cml.CMLDouble{Tokens: []string{left, right}, Relations: []string{string(rel)}})
+	})
+}
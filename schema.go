@@ -0,0 +1,613 @@
+package cml
+
+/**
+形状模式(schema)不列入语法内核，用于在入库前拒绝不符合约定形状的片段:
+1、形状行声明 token 关系符 token ... 的期望序列，token位写名称或 *(任意)，
+   关系符位写 @ . + :、_(组合关系) 或 *(任意关系)
+2、括号声明以关系符开头的片段组，后缀 ? 表示可选、* 表示零次或多次、+ 表示一次或多次，例如 (+ Person)*
+3、定义行 名称 = 约束，为同名token位添加约束：/正则/、{枚举, 枚举}、len(最小..最大)，
+   以 & 表示同时满足、| 表示满足其一，& 优先；未定义的名称不做约束
+4、# 开头的行为注释
+示例:
+	Concept : Person (+ Person)* (@ Year)?
+	Person = len(1..32) & /^\p{Han}+$/
+	Year = /^\d{4}$/
+	Concept = {万有引力, 相对论}
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Schema 编译后的形状模式，可并发复用
+type Schema struct {
+	src   string
+	first *schemaSlot
+	prog  []schemaInst
+}
+
+// Violation 片段不符合形状模式的一处问题
+type Violation struct {
+	Index int    `json:"index"`          // token索引；关系符问题指其右侧的token，片段提前结束时为token总数
+	Name  string `json:"name,omitempty"` // 期望的token位名称，形状问题时可能为空
+	Msg   string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("token %d: %s", v.Index, v.Msg)
+}
+
+// token位
+type schemaSlot struct {
+	name  string // 空串表示 *
+	check *schemaConstraint
+}
+
+func (s *schemaSlot) ok(token string) bool {
+	return s.check == nil || s.check.ok(token)
+}
+
+func (s *schemaSlot) String() string {
+	if s.name == "" {
+		return "*"
+	}
+	return s.name
+}
+
+// 形状中的一步：关系符+token位，或者片段组
+type schemaStep struct {
+	rel   Relation // 0 表示任意关系符
+	slot  *schemaSlot
+	group *schemaGroup
+}
+
+func (st schemaStep) String() string {
+	if st.group != nil {
+		return "(" + joinSteps(st.group.steps) + ")" + st.group.suffix
+	}
+	rel := "*"
+	if st.rel == RelCombine {
+		rel = "_"
+	} else if st.rel != 0 {
+		rel = string(st.rel.Symbol())
+	}
+	return rel + " " + st.slot.String()
+}
+
+func joinSteps(steps []schemaStep) string {
+	parts := make([]string, len(steps))
+	for i, st := range steps {
+		parts[i] = st.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+type schemaGroup struct {
+	steps  []schemaStep
+	suffix string // 空串、?、* 或 +
+}
+
+// 约束：满足任一 all 组，组内的原子约束全部满足
+type schemaConstraint struct {
+	src string
+	any [][]schemaAtom
+}
+
+type schemaAtom struct {
+	re     *regexp.Regexp
+	enum   map[string]bool
+	minLen int
+	maxLen int // -1 表示不限
+}
+
+func (c *schemaConstraint) ok(token string) bool {
+	for _, all := range c.any {
+		pass := true
+		for _, a := range all {
+			if !a.ok(token) {
+				pass = false
+				break
+			}
+		}
+		if pass {
+			return true
+		}
+	}
+	return false
+}
+
+func (a schemaAtom) ok(token string) bool {
+	switch {
+	case a.re != nil:
+		return a.re.MatchString(token)
+	case a.enum != nil:
+		return a.enum[token]
+	}
+	n := utf8.RuneCountInString(token)
+	return n >= a.minLen && (a.maxLen < 0 || n <= a.maxLen)
+}
+
+/**
+------------------------ 编译 --------------------------
+*/
+
+// CompileSchema 编译形状模式，语法错误返回带行列号的 *SyntaxError
+func CompileSchema(src string) (*Schema, error) {
+	sc := &prettyScanner{src: src}
+	defs := map[string]*schemaConstraint{}
+	defOffsets := map[string]int{}
+	shapeOffset, shapeLine := -1, ""
+
+	for offset := 0; offset < len(src); {
+		end := strings.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += offset
+		}
+		line := src[offset:end]
+		trimmed := strings.TrimSpace(line)
+		lineStart := offset + strings.Index(line, trimmed)
+		offset = end + 1
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case isSchemaDef(trimmed):
+			eq := strings.IndexByte(trimmed, '=')
+			name := strings.TrimSpace(trimmed[:eq])
+			if _, dup := defs[name]; dup {
+				return nil, sc.errorf(lineStart, "名称 %s 重复定义", name)
+			}
+			c, err := parseConstraint(sc, trimmed[eq+1:], lineStart+eq+1)
+			if err != nil {
+				return nil, err
+			}
+			defs[name], defOffsets[name] = c, lineStart
+		default:
+			if shapeOffset >= 0 {
+				return nil, sc.errorf(lineStart, "只能有一个形状行")
+			}
+			shapeOffset, shapeLine = lineStart, trimmed
+		}
+	}
+	if shapeOffset < 0 {
+		return nil, sc.errorf(len(src), "缺少形状行")
+	}
+
+	p := &schemaParser{sc: sc, defs: defs, used: map[string]bool{}}
+	if err := p.lex(shapeLine, shapeOffset); err != nil {
+		return nil, err
+	}
+	s := &Schema{src: src}
+	var err error
+	if s.first, err = p.slot(); err != nil {
+		return nil, err
+	}
+	steps, err := p.steps(false)
+	if err != nil {
+		return nil, err
+	}
+	s.prog = append(compileSteps(nil, steps), schemaInst{op: instMatch})
+	for name, off := range defOffsets {
+		if !p.used[name] {
+			return nil, sc.errorf(off, "名称 %s 已定义但没有在形状中使用", name)
+		}
+	}
+	return s, nil
+}
+
+// MustCompileSchema 编译失败时 panic，用于包级变量初始化
+func MustCompileSchema(src string) *Schema {
+	s, err := CompileSchema(src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String 返回编译前的源文本
+func (s *Schema) String() string {
+	return s.src
+}
+
+func isSchemaIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSchemaIdent(s string) bool {
+	if s == "" || s == "_" {
+		return false
+	}
+	for _, r := range s {
+		if !isSchemaIdentRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// 定义行：名称 = 约束
+func isSchemaDef(line string) bool {
+	eq := strings.IndexByte(line, '=')
+	return eq > 0 && isSchemaIdent(strings.TrimSpace(line[:eq]))
+}
+
+// 约束：原子 (& 原子)* (| 原子 (& 原子)*)*
+func parseConstraint(sc *prettyScanner, src string, base int) (*schemaConstraint, error) {
+	c := &schemaConstraint{src: strings.TrimSpace(src)}
+	all := []schemaAtom{}
+	i := 0
+	for {
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+		if i >= len(src) {
+			return nil, sc.errorf(base+i, "缺少约束")
+		}
+		atom, next, err := parseAtom(sc, src, i, base)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, atom)
+		i = next
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+		if i >= len(src) {
+			c.any = append(c.any, all)
+			return c, nil
+		}
+		switch src[i] {
+		case '&':
+		case '|':
+			c.any = append(c.any, all)
+			all = []schemaAtom{}
+		default:
+			return nil, sc.errorf(base+i, "约束之间需要 & 或 |")
+		}
+		i++
+	}
+}
+
+func parseAtom(sc *prettyScanner, src string, i, base int) (schemaAtom, int, error) {
+	switch {
+	case src[i] == '/':
+		var sb strings.Builder
+		for j := i + 1; j < len(src); j++ {
+			if src[j] == '\\' && j+1 < len(src) && src[j+1] == '/' {
+				sb.WriteByte('/')
+				j++
+				continue
+			}
+			if src[j] == '/' {
+				re, err := regexp.Compile(sb.String())
+				if err != nil {
+					return schemaAtom{}, 0, sc.errorf(base+i, "非法的正则: %v", err)
+				}
+				return schemaAtom{re: re}, j + 1, nil
+			}
+			sb.WriteByte(src[j])
+		}
+		return schemaAtom{}, 0, sc.errorf(base+i, "正则缺少结尾的 /")
+	case src[i] == '{':
+		end := strings.IndexByte(src[i:], '}')
+		if end < 0 {
+			return schemaAtom{}, 0, sc.errorf(base+i, "枚举缺少结尾的 }")
+		}
+		enum := map[string]bool{}
+		for _, item := range strings.Split(src[i+1:i+end], ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				return schemaAtom{}, 0, sc.errorf(base+i, "枚举中有空项")
+			}
+			enum[item] = true
+		}
+		return schemaAtom{enum: enum}, i + end + 1, nil
+	case strings.HasPrefix(src[i:], "len("):
+		end := strings.IndexByte(src[i:], ')')
+		if end < 0 {
+			return schemaAtom{}, 0, sc.errorf(base+i, "len 缺少结尾的 )")
+		}
+		body := src[i+len("len(") : i+end]
+		lo, hi, ok := strings.Cut(body, "..")
+		if !ok {
+			hi = lo
+		}
+		a := schemaAtom{maxLen: -1}
+		var err error
+		if lo = strings.TrimSpace(lo); lo != "" {
+			if a.minLen, err = strconv.Atoi(lo); err != nil || a.minLen < 0 {
+				return schemaAtom{}, 0, sc.errorf(base+i, "非法的长度下限: %q", lo)
+			}
+		}
+		if hi = strings.TrimSpace(hi); hi != "" {
+			if a.maxLen, err = strconv.Atoi(hi); err != nil || a.maxLen < a.minLen {
+				return schemaAtom{}, 0, sc.errorf(base+i, "非法的长度上限: %q", hi)
+			}
+		}
+		return a, i + end + 1, nil
+	}
+	return schemaAtom{}, 0, sc.errorf(base+i, "未知的约束，应为 /正则/、{枚举} 或 len(..)")
+}
+
+// 形状行的词法单元
+type schemaLexeme struct {
+	text   string
+	offset int
+}
+
+type schemaParser struct {
+	sc   *prettyScanner
+	defs map[string]*schemaConstraint
+	used map[string]bool
+	lex_ []schemaLexeme
+	pos  int
+	end  int // 形状行末尾的偏移，用于报告缺少的内容
+}
+
+// 切分形状行：名称、* 以及单字符符号，紧跟 ) 的 ? * + 作为量词并入 )
+func (p *schemaParser) lex(line string, base int) error {
+	p.end = base + len(line)
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case r == ' ' || r == '\t':
+			i += size
+		case isSchemaIdentRune(r):
+			j := i
+			for j < len(line) {
+				r, size := utf8.DecodeRuneInString(line[j:])
+				if !isSchemaIdentRune(r) {
+					break
+				}
+				j += size
+			}
+			p.lex_ = append(p.lex_, schemaLexeme{line[i:j], base + i})
+			i = j
+		case r == ')':
+			j := i + 1
+			if j < len(line) && strings.IndexByte("?*+", line[j]) >= 0 {
+				j++
+			}
+			p.lex_ = append(p.lex_, schemaLexeme{line[i:j], base + i})
+			i = j
+		case strings.ContainsRune("@.+:*(", r):
+			p.lex_ = append(p.lex_, schemaLexeme{line[i : i+1], base + i})
+			i++
+		default:
+			return p.sc.errorf(base+i, "形状中非法的字符 %q", r)
+		}
+	}
+	return nil
+}
+
+func (p *schemaParser) peek() (schemaLexeme, bool) {
+	if p.pos >= len(p.lex_) {
+		return schemaLexeme{offset: p.end}, false
+	}
+	return p.lex_[p.pos], true
+}
+
+func (p *schemaParser) slot() (*schemaSlot, error) {
+	lx, ok := p.peek()
+	if !ok {
+		return nil, p.sc.errorf(lx.offset, "缺少token位")
+	}
+	p.pos++
+	if lx.text == "*" {
+		return &schemaSlot{}, nil
+	}
+	if lx.text == "_" || !isSchemaIdent(lx.text) {
+		return nil, p.sc.errorf(lx.offset, "期望token位，实际为 %q", lx.text)
+	}
+	p.used[lx.text] = true
+	return &schemaSlot{name: lx.text, check: p.defs[lx.text]}, nil
+}
+
+// 解析若干步，inGroup 时遇到 ) 结束
+func (p *schemaParser) steps(inGroup bool) ([]schemaStep, error) {
+	var steps []schemaStep
+	for {
+		lx, ok := p.peek()
+		if !ok {
+			if inGroup {
+				return nil, p.sc.errorf(lx.offset, "片段组缺少 )")
+			}
+			return steps, nil
+		}
+		if lx.text[0] == ')' {
+			if !inGroup {
+				return nil, p.sc.errorf(lx.offset, "多余的 )")
+			}
+			return steps, nil
+		}
+		p.pos++
+		if lx.text == "(" {
+			group, err := p.group(lx.offset)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, schemaStep{group: group})
+			continue
+		}
+		var rel Relation
+		switch lx.text {
+		case "*":
+		case "_":
+			rel = RelCombine
+		default:
+			if len(lx.text) != 1 || !Relation(lx.text[0]).IsValid() {
+				return nil, p.sc.errorf(lx.offset, "期望关系符，实际为 %q", lx.text)
+			}
+			rel = Relation(lx.text[0])
+		}
+		slot, err := p.slot()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, schemaStep{rel: rel, slot: slot})
+	}
+}
+
+func (p *schemaParser) group(offset int) (*schemaGroup, error) {
+	steps, err := p.steps(true)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, p.sc.errorf(offset, "空的片段组")
+	}
+	closing := p.lex_[p.pos]
+	p.pos++
+	return &schemaGroup{steps: steps, suffix: closing.text[1:]}, nil
+}
+
+// 形状编译为指令序列，片段组的量词展开为分支与跳转，校验时不需要计数
+type schemaInst struct {
+	op   uint8
+	step schemaStep // instSlot 的关系符与token位
+	x, y int        // instSplit 优先尝试 x，instJmp 跳转到 x
+}
+
+const (
+	instSlot = iota
+	instSplit
+	instJmp
+	instMatch
+)
+
+func compileSteps(prog []schemaInst, steps []schemaStep) []schemaInst {
+	for _, st := range steps {
+		if st.group == nil {
+			prog = append(prog, schemaInst{op: instSlot, step: st})
+			continue
+		}
+		switch st.group.suffix {
+		case "?":
+			split := len(prog)
+			prog = compileSteps(append(prog, schemaInst{op: instSplit, x: split + 1}), st.group.steps)
+			prog[split].y = len(prog)
+		case "*":
+			split := len(prog)
+			prog = compileSteps(append(prog, schemaInst{op: instSplit, x: split + 1}), st.group.steps)
+			prog = append(prog, schemaInst{op: instJmp, x: split})
+			prog[split].y = len(prog)
+		case "+":
+			body := len(prog)
+			prog = compileSteps(prog, st.group.steps)
+			prog = append(prog, schemaInst{op: instSplit, x: body, y: len(prog) + 1})
+		default:
+			prog = compileSteps(prog, st.group.steps)
+		}
+	}
+	return prog
+}
+
+/**
+------------------------ 校验 --------------------------
+*/
+
+// Validate 校验片段，符合形状时返回 nil
+// 1、关系符与token数量无法匹配形状时，返回一条位于最远匹配位置的问题
+// 2、形状匹配但token不满足约束时，返回全部不满足约束的token；存在多种匹配方式时优先选择约束全部满足的一种
+func (s *Schema) Validate(f *CMLDouble) []Violation {
+	if err := f.IsValid(); err != nil {
+		return []Violation{{Index: 0, Msg: err.Error()}}
+	}
+	r := &schemaRun{prog: s.prog, f: f, assign: make([]*schemaSlot, len(f.Tokens)), farIdx: -1}
+	r.assign[0] = s.first
+
+	// 第一遍在分配token位时检查约束，找到的匹配即为合法
+	r.reset(true)
+	if s.first.ok(f.Tokens[0]) && r.run(0, 0) {
+		return nil
+	}
+	// 第二遍只匹配形状，记录最远的失败位置，匹配成功时报告该匹配方式下不满足约束的token
+	r.reset(false)
+	if !r.run(0, 0) {
+		return []Violation{{Index: r.farIdx, Msg: r.farMsg}}
+	}
+	var vs []Violation
+	for idx, tok := range f.Tokens {
+		if slot := r.assign[idx]; !slot.ok(tok) {
+			vs = append(vs, Violation{Index: idx, Name: slot.name,
+				Msg: fmt.Sprintf("%q 不满足 %s = %s", tok, slot.name, slot.check.src)})
+		}
+	}
+	return vs
+}
+
+// ValidateEncoded 以不可信输入的限制解码后校验，适用于入库前的检查
+func (s *Schema) ValidateEncoded(encoded string) ([]Violation, error) {
+	f, err := CML2FragmentsWith(encoded, UntrustedDecodeOptions())
+	if err != nil {
+		return nil, err
+	}
+	return s.Validate(f), nil
+}
+
+// 一次校验的回溯状态，i 为已匹配的最后一个token的索引
+// 同一 (指令, token索引) 的结果与到达路径无关，失败过或正在尝试的状态不再重复进入，
+// 校验耗时与 指令数×token数 成正比
+type schemaRun struct {
+	prog    []schemaInst
+	f       *CMLDouble
+	assign  []*schemaSlot
+	visited []bool
+	enforce bool
+	farIdx  int
+	farMsg  string
+}
+
+func (r *schemaRun) reset(enforce bool) {
+	r.enforce = enforce
+	r.visited = make([]bool, len(r.prog)*len(r.f.Tokens))
+}
+
+// 只记录形状问题，约束问题在第二遍统一报告
+func (r *schemaRun) fail(idx int, format string, args ...any) {
+	if !r.enforce && idx > r.farIdx {
+		r.farIdx, r.farMsg = idx, fmt.Sprintf(format, args...)
+	}
+}
+
+func (r *schemaRun) run(pc, i int) bool {
+	key := pc*len(r.f.Tokens) + i
+	if r.visited[key] {
+		return false
+	}
+	r.visited[key] = true
+
+	in := r.prog[pc]
+	switch in.op {
+	case instSplit:
+		return r.run(in.x, i) || r.run(in.y, i)
+	case instJmp:
+		return r.run(in.x, i)
+	case instMatch:
+		if i+1 < len(r.f.Tokens) {
+			r.fail(i+1, "多余的token %q", r.f.Tokens[i+1])
+			return false
+		}
+		return true
+	}
+	st := in.step
+	if i+1 >= len(r.f.Tokens) {
+		r.fail(i+1, "片段提前结束，期望 %s", st)
+		return false
+	}
+	if st.rel != 0 && r.f.RelationAt(i) != st.rel {
+		r.fail(i+1, "期望 %s，实际关系符为 %q", st, r.f.Relations[i])
+		return false
+	}
+	if r.enforce && !st.slot.ok(r.f.Tokens[i+1]) {
+		return false
+	}
+	r.assign[i+1] = st.slot
+	return r.run(pc+1, i+1)
+}
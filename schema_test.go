@@ -0,0 +1,132 @@
+package cml_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+const conceptSchema = `
+# 概念 : 人物 (+ 人物)* (@ 年份)?
+Concept : Person (+ Person)* (@ Year)?
+Person = len(1..8) & /^\p{Han}+$/ | {Newton}
+Year = /^\d{4}$/
+`
+
+// 形状模式校验测试
+func TestCML_Schema(t *testing.T) {
+	s, err := cml.CompileSchema(conceptSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		fragments  *cml.CMLDouble
+		violations []cml.Violation
+	}{
+		{name: "最短形状", fragments: cml.Build("万有引力").Map("牛顿")},
+		{name: "重复与可选", fragments: cml.Build("万有引力").Map("牛顿").Set("Newton").Set("胡克").Remark("1687")},
+		{name: "关系符不符", fragments: cml.Build("万有引力").Remark("牛顿"),
+			violations: []cml.Violation{{Index: 1, Msg: `期望 : Person，实际关系符为 "@"`}}},
+		{name: "提前结束", fragments: cml.Build("万有引力"),
+			violations: []cml.Violation{{Index: 1, Msg: "片段提前结束，期望 : Person"}}},
+		{name: "多余的token", fragments: cml.Build("万有引力").Map("牛顿").Remark("1687").Set("胡克"),
+			violations: []cml.Violation{{Index: 3, Msg: `多余的token "胡克"`}}},
+		{name: "约束不满足", fragments: cml.Build("万有引力").Map("Hooke").Set("牛顿").Remark("明末"),
+			violations: []cml.Violation{
+				{Index: 1, Name: "Person", Msg: `"Hooke" 不满足 Person = len(1..8) & /^\p{Han}+$/ | {Newton}`},
+				{Index: 3, Name: "Year", Msg: `"明末" 不满足 Year = /^\d{4}$/`},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.violations, s.Validate(tt.fragments))
+		})
+	}
+
+	encoded, err := cml.Build("万有引力").Map("牛顿").EncodeQ()
+	require.NoError(t, err)
+	vs, err := s.ValidateEncoded(encoded)
+	require.NoError(t, err)
+	require.Nil(t, vs)
+}
+
+// 存在多种匹配方式时优先选择约束全部满足的一种
+func TestCML_SchemaBacktrack(t *testing.T) {
+	s := cml.MustCompileSchema(`
+* (* Item)* * Last
+Last = {end}
+`)
+	require.Nil(t, s.Validate(cml.Build("a").Set("b").Line("end")))
+	require.Nil(t, s.Validate(cml.Build("a").Combine("end")))
+
+	vs := s.Validate(cml.Build("a").Set("b").Line("c"))
+	require.Len(t, vs, 1)
+	require.Equal(t, 2, vs[0].Index)
+	require.Equal(t, "Last", vs[0].Name)
+}
+
+// 形状模式语法错误带行列号
+func TestCML_SchemaSyntaxError(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		line   int
+		column int
+	}{
+		{name: "缺少形状行", src: "# 注释\n", line: 2, column: 1},
+		{name: "多个形状行", src: "A : B\nA . B", line: 2, column: 1},
+		{name: "以关系符开头", src: ": A", line: 1, column: 1},
+		{name: "片段组未闭合", src: "A (+ B", line: 1, column: 7},
+		{name: "片段组以token开头", src: "A (B)", line: 1, column: 4},
+		{name: "非法字符", src: "A : B ! C", line: 1, column: 7},
+		{name: "未知约束", src: "A : B\nB = ~x", line: 2, column: 5},
+		{name: "非法正则", src: "A : B\nB = /(/", line: 2, column: 5},
+		{name: "非法长度", src: "A : B\nB = len(3..1)", line: 2, column: 5},
+		{name: "未使用的定义", src: "A : B\nC = {x}", line: 2, column: 1},
+		{name: "重复定义", src: "A : B\nB = {x}\nB = {y}", line: 3, column: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cml.CompileSchema(tt.src)
+			var se *cml.SyntaxError
+			require.True(t, errors.As(err, &se), "%v", err)
+			require.Equal(t, tt.line, se.Line, se.Error())
+			require.Equal(t, tt.column, se.Column, se.Error())
+		})
+	}
+}
+
+// 嵌套的重复片段组不能导致指数级回溯
+func TestCML_SchemaNestedGroups(t *testing.T) {
+	ast := require.New(t)
+	s := cml.MustCompileSchema(`
+X ((: Y)+)* (. Z)?
+Y = /^y/
+`)
+	ast.Nil(s.Validate(cml.Build("x").Map("y1").Map("y2").Line("z")))
+
+	f := cml.Build("x")
+	for range 3000 {
+		f.Map("y")
+	}
+	ast.Nil(s.Validate(f))
+
+	start := time.Now()
+	f.Map("bad")
+	vs := s.Validate(f)
+	ast.Equal([]cml.Violation{{Index: 3001, Name: "Y", Msg: `"bad" 不满足 Y = /^y/`}}, vs)
+
+	f.Set("y")
+	vs = s.Validate(f)
+	ast.Len(vs, 1)
+	ast.Equal(3002, vs[0].Index)
+	ast.Less(time.Since(start), 5*time.Second)
+
+	// 可以匹配空串的片段组重复时不能死循环
+	empty := cml.MustCompileSchema(`X ((: Y)?)* + Z`)
+	ast.Nil(empty.Validate(cml.Build("x").Map("y").Set("z")))
+	ast.Nil(empty.Validate(cml.Build("x").Set("z")))
+}
@@ -0,0 +1,56 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 以 Sequence 接口编写的泛型消费方
+func encodeAll(seq cml.Sequence) ([]string, error) {
+	var out []string
+	for _, encode := range []func() (string, error){seq.EncodeA, seq.EncodeC, seq.EncodeP, seq.EncodeQ} {
+		s, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// 单序列与双序列互转测试
+func TestCML_SequenceConversion(t *testing.T) {
+	ast := require.New(t)
+
+	double := cml.Build("a.b").Map("牛顿").Set("集合").Remark("x!")
+	single, err := double.ToSingle()
+	ast.NoError(err)
+	ast.Equal(double.Len(), single.Len())
+	for i := 0; i < double.Len(); i++ {
+		ast.Equal(double.At(i), single.At(i))
+	}
+
+	back, err := single.ToDouble()
+	ast.NoError(err)
+	ast.Equal(double, back)
+
+	fromDouble, err := encodeAll(double)
+	ast.NoError(err)
+	fromSingle, err := encodeAll(single)
+	ast.NoError(err)
+	ast.Equal(fromDouble, fromSingle)
+
+	// 交替规律被破坏时拒绝转换
+	broken := cml.CMLSingle{
+		{Type: cml.TypeToken, Value: "A"},
+		{Type: cml.TypeToken, Value: "B"},
+		{Type: cml.TypeToken, Value: "C"},
+	}
+	_, err = broken.ToDouble()
+	ast.Error(err)
+
+	_, err = (&cml.CMLDouble{Tokens: []string{"A"}, Relations: []string{"@"}}).ToSingle()
+	ast.Error(err)
+}
@@ -0,0 +1,68 @@
+package cml_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/shengdoushi/base58"
+	"github.com/stretchr/testify/require"
+)
+
+// 严格模式测试：编码器的输出全部接受，各类非规范编码按类别拒绝，非严格模式仍兼容
+func TestCML_StrictDecode(t *testing.T) {
+	ast := require.New(t)
+	strict := &cml.DecodeOptions{Strict: true}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	// 编码器产生的编码必须被严格模式接受
+	r := rand.New(rand.NewPCG(4, 5))
+	for range 50 {
+		f := cml.Build(randomToken(r, 1+r.IntN(12))).Map("a!b").Set("x y").Line(randomToken(r, 3))
+		for _, mode := range []uint8{cml.ModeA, cml.ModeC, cml.ModeQ, cml.ModeP} {
+			encoded, err := f.Encode(mode)
+			ast.NoError(err)
+			_, err = cml.CML2FragmentsWith(encoded, strict)
+			ast.NoError(err, encoded)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		encoded string
+		kind    cml.StrictKind
+	}{
+		{"不必要的转义", "p" + b64([]byte("abc")) + "!:B", cml.StrictNeedlessEscape},
+		{"q模式不必要的转义", "q" + b64([]byte("A."+b64([]byte("x"))+"!")), cml.StrictNeedlessEscape},
+		{"整体层填充位非零", "cUVF", cml.StrictNonCanonicalBase64},
+		{"c模式token填充位非零", "c" + b64([]byte("QR:QQ")), cml.StrictNonCanonicalBase64},
+		{"整体层含换行", "c" + b64([]byte("QQ"))[:2] + "\n" + b64([]byte("QQ"))[2:], cml.StrictNonCanonicalBase64},
+		{"转义token填充位非零", "pQR!", cml.StrictNonCanonicalBase64},
+		{"明文非法UTF-8", "p\xff:B", cml.StrictInvalidUTF8},
+		{"c模式token非法UTF-8", "c" + b64([]byte(b64([]byte{0xe4, 0xb8}))), cml.StrictInvalidUTF8},
+		{"明文未转义的!", "pa!b", cml.StrictUnescapedReserved},
+	}
+	for _, c := range cases {
+		_, err := cml.CML2Fragments(c.encoded)
+		ast.NoError(err, "%s: 非严格模式应当兼容", c.name)
+
+		_, err = cml.CML2FragmentsWith(c.encoded, strict)
+		var se *cml.StrictError
+		ast.True(errors.As(err, &se), "%s: %v", c.name, err)
+		ast.Equal(c.kind, se.Kind, c.name)
+
+		_, err = cml.CML2ElementsWith(c.encoded, strict)
+		ast.ErrorAs(err, &se, c.name)
+	}
+
+	// a 模式的非法 UTF-8
+	token := base58.Encode([]byte{0xff, 0xfe}, base58.BitcoinAlphabet)
+	encoded := "a" + base58.Encode([]byte(token), base58.BitcoinAlphabet)
+	for _, err := range cml.ElementsWith(encoded, strict) {
+		var se *cml.StrictError
+		ast.ErrorAs(err, &se)
+		ast.Equal(cml.StrictInvalidUTF8, se.Kind)
+	}
+}
@@ -0,0 +1,148 @@
+package cml
+
+/**
+可视化不列入语法内核，将单个CML表达式渲染为 Mermaid 或 Graphviz 图:
+1、每个token是一个节点，关系边以关系符的语义名称作为标签
+2、以 + 连续连接的token收进同一个 set 分组，分组两侧的关系边连接到分组本身
+3、@ 右侧的补充token使用独立的形状，补充关系边为虚线
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 渲染前的结构：token节点、set分组与分组之间的关系边
+type vizLayout struct {
+	tokens []string
+	remark []bool    // token是否作为 @ 的右侧出现
+	groups [][2]int  // 每个分组的 [首, 尾] token索引，单个token也是一个分组
+	edges  []vizEdge // 分组之间的关系边
+}
+
+type vizEdge struct {
+	from, to int // 分组索引
+	rel      Relation
+}
+
+func layoutOf(encoded string) (*vizLayout, error) {
+	f, err := CML2Fragments(encoded)
+	if err != nil {
+		return nil, err
+	}
+	l := &vizLayout{tokens: f.Tokens, remark: make([]bool, len(f.Tokens))}
+	start := 0
+	for i := range f.Relations {
+		rel := f.RelationAt(i)
+		if rel == RelSet {
+			continue
+		}
+		l.groups = append(l.groups, [2]int{start, i})
+		l.edges = append(l.edges, vizEdge{from: len(l.groups) - 1, to: len(l.groups), rel: rel})
+		if rel == RelRemark {
+			l.remark[i+1] = true
+		}
+		start = i + 1
+	}
+	l.groups = append(l.groups, [2]int{start, len(f.Tokens) - 1})
+	return l, nil
+}
+
+// ToMermaid 将CML编码渲染为 Mermaid flowchart
+func ToMermaid(encoded string) (string, error) {
+	l, err := layoutOf(encoded)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	node := func(indent string, i int) {
+		if l.remark[i] {
+			fmt.Fprintf(&sb, "%st%d>%s]\n", indent, i, mermaidQuote(l.tokens[i]))
+		} else {
+			fmt.Fprintf(&sb, "%st%d[%s]\n", indent, i, mermaidQuote(l.tokens[i]))
+		}
+	}
+	for g, span := range l.groups {
+		if span[0] == span[1] {
+			node("  ", span[0])
+			continue
+		}
+		fmt.Fprintf(&sb, "  subgraph g%d[%s]\n", g, mermaidQuote(RelSet.Name()))
+		for i := span[0]; i <= span[1]; i++ {
+			node("    ", i)
+		}
+		sb.WriteString("  end\n")
+	}
+	endpoint := func(g int) string {
+		if span := l.groups[g]; span[0] != span[1] {
+			return fmt.Sprintf("g%d", g)
+		}
+		return fmt.Sprintf("t%d", l.groups[g][0])
+	}
+	for _, e := range l.edges {
+		arrow := "-->"
+		if e.rel == RelRemark {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "  %s %s|%s| %s\n", endpoint(e.from), arrow, e.rel.Name(), endpoint(e.to))
+	}
+	return sb.String(), nil
+}
+
+// Mermaid 节点文本使用双引号包裹，内部的双引号转为实体
+func mermaidQuote(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "\n", " ")
+	return `"` + r.Replace(s) + `"`
+}
+
+// ToDOT 将CML编码渲染为 Graphviz DOT
+func ToDOT(encoded string) (string, error) {
+	l, err := layoutOf(encoded)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph cml {\n  rankdir=LR;\n  compound=true;\n")
+	node := func(indent string, i int) {
+		attrs := "shape=box"
+		if l.remark[i] {
+			attrs = "shape=note, style=dashed"
+		}
+		fmt.Fprintf(&sb, "%st%d [label=%s, %s];\n", indent, i, QuoteDOT(l.tokens[i]), attrs)
+	}
+	for g, span := range l.groups {
+		if span[0] == span[1] {
+			node("  ", span[0])
+			continue
+		}
+		fmt.Fprintf(&sb, "  subgraph cluster_g%d {\n    label=%s;\n    style=rounded;\n", g, QuoteDOT(RelSet.Name()))
+		for i := span[0]; i <= span[1]; i++ {
+			node("    ", i)
+		}
+		sb.WriteString("  }\n")
+	}
+	for _, e := range l.edges {
+		from, to := l.groups[e.from], l.groups[e.to]
+		// 边连接分组时，借助 compound 的 ltail/lhead 让边止于分组边框
+		attrs := []string{"label=" + QuoteDOT(e.rel.Name())}
+		if from[0] != from[1] {
+			attrs = append(attrs, fmt.Sprintf("ltail=cluster_g%d", e.from))
+		}
+		if to[0] != to[1] {
+			attrs = append(attrs, fmt.Sprintf("lhead=cluster_g%d", e.to))
+		}
+		if e.rel == RelRemark {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&sb, "  t%d -> t%d [%s];\n", from[1], to[0], strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// QuoteDOT 转换为 Graphviz DOT 的双引号字符串，转义反斜杠、双引号和换行
+func QuoteDOT(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
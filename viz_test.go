@@ -0,0 +1,38 @@
+package cml_test
+
+import (
+	"testing"
+
+	"github.com/ContextMark/cml-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 可视化测试
+func TestCML_Viz(t *testing.T) {
+	ast := require.New(t)
+	encoded, err := cml.Build("万有引力").Map("牛顿").Set(`"原理"`).Remark("1687 年").EncodeC()
+	ast.NoError(err)
+
+	mermaid, err := cml.ToMermaid(encoded)
+	ast.NoError(err)
+	ast.Equal(`graph LR
+  t0["万有引力"]
+  subgraph g1["set"]
+    t1["牛顿"]
+    t2["#quot;原理#quot;"]
+  end
+  t3>"1687 年"]
+  t0 -->|mapping| g1
+  g1 -.->|remark| t3
+`, mermaid)
+
+	dot, err := cml.ToDOT(encoded)
+	ast.NoError(err)
+	ast.Contains(dot, `subgraph cluster_g1 {`)
+	ast.Contains(dot, `t2 [label="\"原理\"", shape=box];`)
+	ast.Contains(dot, `t0 -> t1 [label="mapping", lhead=cluster_g1];`)
+	ast.Contains(dot, `t2 -> t3 [label="remark", ltail=cluster_g1, style=dashed];`)
+
+	_, err = cml.ToMermaid("INVALID")
+	ast.Error(err)
+}